@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+
+	"github.com/uber-common/bark"
+)
+
+var (
+	metricBackendHealthy                 = metrics.MustRegisterGauge("backend_healthy", "Whether HTTP backend passes health checks (1) or not (0)")
+	metricBackendHealthChecksFailedTotal = metrics.MustRegisterCounter("backend_health_checks_failed_total", "Total number of failed HTTP backend health checks")
+)
+
+// HealthCheckConfig describes how HTTP backend is probed
+type HealthCheckConfig struct {
+	// Path is a HTTP path that is requested on backend, any 2xx or 3xx response means success
+	Path string
+	// Interval is a period between probes
+	Interval time.Duration
+	// Timeout limits a single probe
+	Timeout time.Duration
+	// HealthyThreshold is a number of consecutive successful probes to mark backend as healthy
+	HealthyThreshold int
+	// UnhealthyThreshold is a number of consecutive failed probes to mark backend as unhealthy
+	UnhealthyThreshold int
+}
+
+// HealthChecker actively probes HTTP backend and tracks its health.
+// Backend is considered healthy until enough probes failed.
+type HealthChecker struct {
+	config HealthCheckConfig
+	client *http.Client
+	url    string
+	logger bark.Logger
+
+	mu        sync.RWMutex
+	healthy   bool
+	successes int
+	failures  int
+	listeners []func(healthy bool)
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewHealthChecker returns new health checker for given backend
+func NewHealthChecker(b *BackendReverseProxy, cfg HealthCheckConfig, logger bark.Logger) *HealthChecker {
+	if cfg.HealthyThreshold < 1 {
+		cfg.HealthyThreshold = 1
+	}
+	if cfg.UnhealthyThreshold < 1 {
+		cfg.UnhealthyThreshold = 1
+	}
+
+	probeURL := *b.proxyURI
+	probeURL.Path = cfg.Path
+
+	metricBackendHealthy.Set(1)
+
+	return &HealthChecker{
+		config: cfg,
+		client: &http.Client{
+			Transport: b.transport,
+			// Redirect is a valid response of probe, there is no need to follow it
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		url:     probeURL.String(),
+		logger:  logger,
+		healthy: true,
+		stop:    make(chan struct{}),
+	}
+}
+
+// OnChange registers func that is called every time backend health changes
+func (hc *HealthChecker) OnChange(fn func(healthy bool)) {
+	hc.mu.Lock()
+	hc.listeners = append(hc.listeners, fn)
+	hc.mu.Unlock()
+}
+
+// Healthy returns whether backend is healthy
+func (hc *HealthChecker) Healthy() bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	return hc.healthy
+}
+
+// Start runs probing in background until Stop is called
+func (hc *HealthChecker) Start() {
+	go func() {
+		ticker := time.NewTicker(hc.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				hc.record(hc.probe())
+			case <-hc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops probing
+func (hc *HealthChecker) Stop() {
+	hc.once.Do(func() {
+		close(hc.stop)
+	})
+}
+
+func (hc *HealthChecker) probe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain body to reuse connection
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// record accounts probe result and switches health state when threshold is reached
+func (hc *HealthChecker) record(err error) {
+	hc.mu.Lock()
+
+	if err != nil {
+		metricBackendHealthChecksFailedTotal.Inc()
+		hc.logger.Warnf("HTTP backend health check failed: %v", err)
+
+		hc.successes = 0
+		hc.failures++
+	} else {
+		hc.failures = 0
+		hc.successes++
+	}
+
+	changed := false
+	switch {
+	case hc.healthy && hc.failures >= hc.config.UnhealthyThreshold:
+		hc.healthy, changed = false, true
+	case !hc.healthy && hc.successes >= hc.config.HealthyThreshold:
+		hc.healthy, changed = true, true
+	}
+
+	healthy := hc.healthy
+	listeners := hc.listeners
+
+	hc.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if healthy {
+		metricBackendHealthy.Set(1)
+		hc.logger.Info("HTTP backend became healthy")
+	} else {
+		metricBackendHealthy.Set(0)
+		hc.logger.Warn("HTTP backend became unhealthy")
+	}
+
+	for _, fn := range listeners {
+		fn(healthy)
+	}
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/uber-common/bark"
+)
+
+func TestHealthCheckerThresholds(t *testing.T) {
+	proxy, err := New("http://127.0.0.1:4000/", TransportConfig{}, bark.NewNopLogger())
+	if err != nil {
+		t.Fatalf("Error on creating backend proxy: %v", err)
+	}
+
+	hc := NewHealthChecker(proxy, HealthCheckConfig{
+		Path:               "/health",
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}, bark.NewNopLogger())
+
+	var changes []bool
+	hc.OnChange(func(healthy bool) {
+		changes = append(changes, healthy)
+	})
+
+	failure := errors.New("connection refused")
+	steps := []struct {
+		err     error
+		healthy bool
+	}{
+		{failure, true},
+		{failure, true},
+		{nil, true}, // success resets failures counter
+		{failure, true},
+		{failure, true},
+		{failure, false},
+		{nil, false},
+		{nil, true},
+	}
+
+	for i, step := range steps {
+		hc.record(step.err)
+		if hc.Healthy() != step.healthy {
+			t.Fatalf("Step %d: unexpected health: %v, expected: %v", i, hc.Healthy(), step.healthy)
+		}
+	}
+
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Fatalf("Unexpected health changes: %v, expected: [false true]", changes)
+	}
+}
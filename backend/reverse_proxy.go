@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -8,24 +9,47 @@ import (
 	"github.com/uber-common/bark"
 )
 
+const schemeUnix = "unix"
+
 // BackendReverseProxy is a wrapper around standard http proxy
 type BackendReverseProxy struct {
-	proxy  *httputil.ReverseProxy
-	target *url.URL
-	logger bark.Logger
+	proxy     *httputil.ReverseProxy
+	transport http.RoundTripper
+	target    *url.URL
+	proxyURI  *url.URL
+	logger    bark.Logger
 }
 
 // New returns new reverse proxy for given backend
-func New(target string, logger bark.Logger) (*BackendReverseProxy, error) {
+//
+// Backend listening on unix domain socket could be given as unix:///var/run/app.sock,
+// in this case requests are sent to it over plain HTTP with untouched path.
+func New(target string, transport TransportConfig, logger bark.Logger) (*BackendReverseProxy, error) {
 	uri, err := url.Parse(target)
 	if err != nil {
 		return nil, err
 	}
 
+	proxyURI := uri
+	if uri.Scheme == schemeUnix {
+		if uri.Path == "" {
+			return nil, fmt.Errorf("socket path is missing in backend URL: %s", target)
+		}
+
+		transport.UnixSocket = uri.Path
+		// Host is not used for dialing, but it's required to build a valid request
+		proxyURI = &url.URL{Scheme: "http", Host: "unix"}
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(proxyURI)
+	proxy.Transport = NewTransport(transport)
+
 	return &BackendReverseProxy{
-		proxy:  httputil.NewSingleHostReverseProxy(uri),
-		target: uri,
-		logger: logger,
+		proxy:     proxy,
+		transport: proxy.Transport,
+		target:    uri,
+		proxyURI:  proxyURI,
+		logger:    logger,
 	}, nil
 }
 
@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uber-common/bark"
+)
+
+func TestNewUnixSocketBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ringpop-backend")
+	if err != nil {
+		t.Fatalf("Error on creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "app.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Error on listening unix socket: %v", err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello from %s", r.URL.Path)
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	proxy, err := New("unix://"+socket, TransportConfig{}, bark.NewNopLogger())
+	if err != nil {
+		t.Fatalf("Error on creating backend proxy: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://localhost/path", nil)
+	proxy.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response code: %d, expected: 200", w.Code)
+	}
+
+	expectedBody := "Hello from /path"
+	if w.Body.String() != expectedBody {
+		t.Fatalf("Unexpected response body: %s, expected: %s", w.Body.String(), expectedBody)
+	}
+}
+
+func TestNewUnixSocketBackendWithoutPath(t *testing.T) {
+	if _, err := New("unix://", TransportConfig{}, bark.NewNopLogger()); err == nil {
+		t.Fatal("Expected error for unix backend without socket path")
+	}
+}
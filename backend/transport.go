@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// TransportConfig describes how connections to HTTP backend are established and reused
+type TransportConfig struct {
+	// DialTimeout limits time spent on establishing new connection
+	DialTimeout time.Duration
+	// KeepAlive is a period between TCP keep-alive probes, negative value disables them
+	KeepAlive time.Duration
+	// ResponseHeaderTimeout limits time spent waiting for response headers after request was written
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is a maximum amount of time an idle connection will remain in the pool
+	IdleConnTimeout time.Duration
+
+	// MaxIdleConns limits number of idle connections in the pool, zero means no limit
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits number of idle connections kept to the backend
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits total number of connections to the backend, zero means no limit
+	MaxConnsPerHost int
+
+	// HTTP2 enables HTTP/2 negotiation (ALPN) for https backends
+	HTTP2 bool
+	// H2C enables HTTP/2 over cleartext TCP (prior knowledge) for http backends.
+	// Single multiplexed connection is kept, so IdleConnTimeout, limits of connections and HTTP2
+	// have no effect with it. KeepAlive also makes idle
+	// connection pinged after the same period, so dead connection is detected.
+	H2C bool
+
+	// UnixSocket is a path of unix domain socket, if set all connections are dialed to it
+	// instead of backend host address
+	UnixSocket string
+}
+
+// NewTransport returns round tripper for HTTP backend configured by given config
+func NewTransport(cfg TransportConfig) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+
+	dial := dialer.DialContext
+	proxy := http.ProxyFromEnvironment
+	if cfg.UnixSocket != "" {
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", cfg.UnixSocket)
+		}
+		// Backend is local, there is no sense to go through proxy
+		proxy = nil
+	}
+
+	if cfg.H2C {
+		transport := &http2.Transport{
+			AllowHTTP: true,
+			// There is no TLS in h2c, so we just dial plain connection, dialer applies timeout and keep-alive
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), network, addr)
+			},
+		}
+		if cfg.KeepAlive > 0 {
+			transport.ReadIdleTimeout = cfg.KeepAlive
+		}
+		if cfg.ResponseHeaderTimeout > 0 {
+			return &responseHeaderTimeoutTransport{transport: transport, timeout: cfg.ResponseHeaderTimeout}
+		}
+		return transport
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// responseHeaderTimeoutTransport cancels request when response headers aren't received in time,
+// http2.Transport has no such option on its own
+type responseHeaderTimeoutTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+func (t *responseHeaderTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, errResponseHeaderTimeout
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Request context must live until body is read
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// errResponseHeaderTimeout is the same as error of http.Transport
+var errResponseHeaderTimeout = errors.New("net/http: timeout awaiting response headers")
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
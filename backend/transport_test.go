@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestNewTransportH2C(t *testing.T) {
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}), &http2.Server{}))
+	defer backend.Close()
+
+	client := &http.Client{Transport: NewTransport(TransportConfig{H2C: true})}
+
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("Error on request to h2c backend: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Unexpected protocol: %s, expected: HTTP/2.0", resp.Proto)
+	}
+}
+
+func TestNewTransportH2CResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}), &http2.Server{}))
+	defer backend.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: NewTransport(TransportConfig{H2C: true, ResponseHeaderTimeout: 50 * time.Millisecond})}
+
+	_, err := client.Get(backend.URL)
+	if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Fatalf("Unexpected error: %v, expected timeout", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ozontech/http-ringpop/ring"
+	"github.com/uber/ringpop-go/swim"
+)
+
+// ring-report compares key distribution and key movement of hashing algorithms on synthetic members
+func main() {
+	nodes := flag.Int("nodes", 10, "Number of members")
+	keys := flag.Int("keys", 100000, "Number of keys")
+	algorithms := flag.String("algorithms", strings.Join(ring.Algorithms(), ","), "Comma separated algorithms to compare")
+	weights := flag.String("weights", "", "Comma separated weights of the first members, e.g. 3,2; other members have weight 1")
+	flag.Parse()
+
+	members, err := newMembers(*nodes, *weights)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "algorithm\tbuild\tlookup\tmin load\tmax load\tstddev\tmoved (remove first)\tmoved (remove last)\tmoved (add)\t")
+
+	for _, algorithm := range strings.Split(*algorithms, ",") {
+		r, err := report(strings.TrimSpace(algorithm), members, *keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Fprintf(w, "%s\t%v\t%v\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n",
+			algorithm, r.build, r.lookup, r.minLoad, r.maxLoad, r.stddev, r.movedRemoveFirst, r.movedRemoveLast, r.movedAdd)
+	}
+	w.Flush()
+
+	fmt.Printf("\nload is a share of keys owned by member divided by its expected share,\n"+
+		"moved is a share of keys that changed owner, ideally it's a share of removed or added member (%.3f of %d members with equal weights)\n",
+		1/float64(*nodes), *nodes)
+}
+
+type result struct {
+	build  time.Duration
+	lookup time.Duration
+
+	minLoad, maxLoad, stddev float64
+
+	movedRemoveFirst, movedRemoveLast, movedAdd float64
+}
+
+func report(algorithm string, members []ring.Member, keys int) (*result, error) {
+	r := &result{}
+
+	start := time.Now()
+	selector, err := ring.NewStaticSelector(algorithm, members)
+	if err != nil {
+		return nil, err
+	}
+	r.build = time.Since(start)
+
+	start = time.Now()
+	owners, err := lookup(selector, keys)
+	if err != nil {
+		return nil, err
+	}
+	r.lookup = time.Since(start) / time.Duration(keys)
+
+	var totalWeight int
+	for _, member := range members {
+		totalWeight += member.Weight()
+	}
+
+	owned := make(map[string]int, len(members))
+	for _, owner := range owners {
+		owned[owner]++
+	}
+
+	r.minLoad = math.Inf(1)
+	var sum, sumSquares float64
+	for _, member := range members {
+		expected := float64(keys) * float64(member.Weight()) / float64(totalWeight)
+		load := float64(owned[member.Address]) / expected
+
+		r.minLoad = math.Min(r.minLoad, load)
+		r.maxLoad = math.Max(r.maxLoad, load)
+		sum += load
+		sumSquares += load * load
+	}
+	mean := sum / float64(len(members))
+	r.stddev = math.Sqrt(sumSquares/float64(len(members)) - mean*mean)
+
+	added := ring.Member{Address: memberAddress(len(members)), Status: swim.Alive}
+
+	if r.movedRemoveFirst, err = moved(algorithm, members[1:], owners, keys); err != nil {
+		return nil, err
+	}
+	if r.movedRemoveLast, err = moved(algorithm, members[:len(members)-1], owners, keys); err != nil {
+		return nil, err
+	}
+	if r.movedAdd, err = moved(algorithm, append(members[:len(members):len(members)], added), owners, keys); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// moved returns share of keys that changed owner after membership change
+func moved(algorithm string, members []ring.Member, owners []string, keys int) (float64, error) {
+	selector, err := ring.NewStaticSelector(algorithm, members)
+	if err != nil {
+		return 0, err
+	}
+
+	changed, err := lookup(selector, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for i := range owners {
+		if owners[i] != changed[i] {
+			count++
+		}
+	}
+
+	return float64(count) / float64(keys), nil
+}
+
+func lookup(selector ring.NodeSelector, keys int) ([]string, error) {
+	owners := make([]string, 0, keys)
+	for i := 0; i < keys; i++ {
+		nodes, err := selector.LookupN("key-"+strconv.Itoa(i), 1)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, nodes[0])
+	}
+
+	return owners, nil
+}
+
+func newMembers(count int, weights string) ([]ring.Member, error) {
+	if count < 2 {
+		return nil, fmt.Errorf("at least 2 members are required: %d", count)
+	}
+
+	members := make([]ring.Member, 0, count)
+	for i := 0; i < count; i++ {
+		members = append(members, ring.Member{Address: memberAddress(i), Status: swim.Alive})
+	}
+
+	if weights == "" {
+		return members, nil
+	}
+
+	for i, value := range strings.Split(weights, ",") {
+		if i >= count {
+			return nil, fmt.Errorf("there are more weights than members: %s", weights)
+		}
+
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 1 || weight > ring.MaxWeight {
+			return nil, fmt.Errorf("invalid weight: %s", value)
+		}
+		members[i] = members[i].WithWeight(weight)
+	}
+
+	return members, nil
+}
+
+func memberAddress(i int) string {
+	return fmt.Sprintf("10.0.%d.%d:5000", i/250, i%250+1)
+}
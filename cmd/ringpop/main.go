@@ -1,32 +1,151 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/ozontech/http-ringpop/backend"
 	"github.com/ozontech/http-ringpop/discovery"
 	ringhttp "github.com/ozontech/http-ringpop/http"
+	"github.com/ozontech/http-ringpop/pkg/breaker"
+	"github.com/ozontech/http-ringpop/pkg/cache"
+	"github.com/ozontech/http-ringpop/pkg/coalesce"
+	"github.com/ozontech/http-ringpop/pkg/drain"
+	"github.com/ozontech/http-ringpop/pkg/health"
+	"github.com/ozontech/http-ringpop/pkg/hedge"
+	"github.com/ozontech/http-ringpop/pkg/hotkey"
+	"github.com/ozontech/http-ringpop/pkg/limiter"
 	"github.com/ozontech/http-ringpop/pkg/metrics"
+	"github.com/ozontech/http-ringpop/pkg/ratelimit"
+	"github.com/ozontech/http-ringpop/pkg/route"
 	"github.com/ozontech/http-ringpop/ring"
 
 	"github.com/sirupsen/logrus"
 	"github.com/uber-common/bark"
 )
 
+const (
+	lookupModeConsistent  = "consistent"
+	lookupModeBoundedLoad = "bounded-load"
+)
+
+var (
+	errDraining         = errors.New("node is shutting down")
+	errBackendUnhealthy = errors.New("backend is unhealthy")
+)
+
 var (
 	httpListenOn    = flag.String("listen.http", ":3000", "hostPort to listen calls from incoming http requests")
-	backendURL      = flag.String("backend.url", "http://127.0.0.1:4000/", "URL of your http backend")
+	backendURL      = flag.String("backend.url", "http://127.0.0.1:4000/", "URL of your http backend, e.g. http://127.0.0.1:4000/ or unix:///var/run/app.sock")
 	ringpopListenOn = flag.String("listen.ringpop", ":5000", "hostPort to listen gossip requests inside hashring")
 	debugListenOn   = flag.String("listen.debug", ":6000", "hostPort to listen calls from incoming debug http requests (metrics, etc.)")
 	logLevel        = flag.Uint("log.level", 4, "Log level, default - INFO (4)")
+	routesFile      = flag.String("routes.file", "", "JSON file with per route settings (rate limits, etc.)")
+
+	backendDialTimeout           = flag.Duration("backend.dial.timeout", 30*time.Second, "Timeout of establishing new connection to HTTP backend")
+	backendKeepAlive             = flag.Duration("backend.keepalive", 30*time.Second, "Period between TCP keep-alive probes to HTTP backend, negative value disables them")
+	backendResponseHeaderTimeout = flag.Duration("backend.response-header.timeout", 0, "Timeout of waiting for HTTP backend response headers, 0 means no timeout")
+	backendIdleConnTimeout       = flag.Duration("backend.idle-conn.timeout", 90*time.Second, "How long idle connection to HTTP backend is kept in the pool")
+	backendMaxIdleConns          = flag.Int("backend.max-idle-conns", 100, "Maximum number of idle connections to HTTP backend")
+	backendMaxIdleConnsPerHost   = flag.Int("backend.max-idle-conns-per-host", http.DefaultMaxIdleConnsPerHost, "Maximum number of idle connections per HTTP backend host")
+	backendMaxConnsPerHost       = flag.Int("backend.max-conns-per-host", 0, "Maximum number of connections per HTTP backend host, 0 means no limit")
+	backendHTTP2                 = flag.Bool("backend.http2", false, "Negotiate HTTP/2 with https backend")
+	backendH2C                   = flag.Bool("backend.h2c", false, "Use HTTP/2 over cleartext TCP (prior knowledge) with http backend")
+
+	backendHealthPath               = flag.String("backend.health.path", "", "HTTP path of backend health check, empty value disables health checking")
+	backendHealthInterval           = flag.Duration("backend.health.interval", 5*time.Second, "Period between backend health checks")
+	backendHealthTimeout            = flag.Duration("backend.health.timeout", time.Second, "Timeout of single backend health check")
+	backendHealthHealthyThreshold   = flag.Int("backend.health.healthy-threshold", 2, "Number of consecutive successful checks to mark backend as healthy")
+	backendHealthUnhealthyThreshold = flag.Int("backend.health.unhealthy-threshold", 3, "Number of consecutive failed checks to mark backend as unhealthy")
+
+	breakerConsecutiveFailures = flag.Int("breaker.consecutive-failures", 0, "Open circuit breaker after given number of consecutive failures, 0 disables the rule")
+	breakerFailureRatio        = flag.Float64("breaker.failure-ratio", 0, "Open circuit breaker when ratio of failures exceeds given value, 0 disables the rule")
+	breakerMinRequests         = flag.Int("breaker.min-requests", 20, "Minimum number of requests in window before failure ratio is taken into account")
+	breakerWindow              = flag.Duration("breaker.window", 10*time.Second, "Period after which failure counters of closed circuit breaker are reset")
+	breakerOpenTimeout         = flag.Duration("breaker.open-timeout", 5*time.Second, "How long open circuit breaker rejects requests before probing")
+	breakerHalfOpenRequests    = flag.Int("breaker.half-open-requests", 1, "Number of successful probes required to close circuit breaker")
+
+	concurrencyMaxLimit         = flag.Int("concurrency.max-limit", 0, "Maximum adaptive concurrency limit of HTTP backend, 0 disables concurrency limiting")
+	concurrencyMinLimit         = flag.Int("concurrency.min-limit", 1, "Minimum adaptive concurrency limit of HTTP backend")
+	concurrencyInitialLimit     = flag.Int("concurrency.initial-limit", 20, "Initial adaptive concurrency limit of HTTP backend")
+	concurrencyBackoffRatio     = flag.Float64("concurrency.backoff-ratio", 0.9, "Multiplier applied to concurrency limit when backend is overloaded")
+	concurrencyLatencyThreshold = flag.Duration("concurrency.latency-threshold", 0, "Backend responses slower than given value decrease concurrency limit, 0 disables the rule")
+	concurrencyMaxQueue         = flag.Int("concurrency.max-queue", 100, "Maximum number of requests waiting for concurrency limit")
+	concurrencyQueueTimeout     = flag.Duration("concurrency.queue-timeout", time.Second, "Maximum time request waits for concurrency limit")
+	concurrencyPriorityHeader   = flag.String("concurrency.priority-header", "X-Priority", "Header with integer priority of request, higher value leaves queue first")
+	concurrencyShedStatus       = flag.Int("concurrency.shed-status", http.StatusServiceUnavailable, "HTTP status of shed requests, e.g. 429 or 503")
+
+	ringpopWeight            = flag.Int("ringpop.weight", 1, "Weight of this node, node owns part of key space proportional to its weight unless lookup algorithm is ringpop")
+	lookupWeighted           = flag.Bool("lookup.weighted", false, "Deprecated, the same as --lookup.algorithm=ring")
+	lookupAlgorithm          = flag.String("lookup.algorithm", ring.AlgorithmRingpop, "Algorithm of key to node mapping: ringpop, "+strings.Join(ring.Algorithms(), ", ")+", must be the same for all nodes")
+	lookupMode               = flag.String("lookup.mode", lookupModeConsistent, "How key owner is resolved: consistent (plain consistent hashing) or bounded-load (consistent hashing with bounded loads)")
+	lookupBoundedLoadEpsilon = flag.Float64("lookup.bounded-load.epsilon", 0.25, "Load of every node is capped at (1+epsilon) times the average load in bounded-load mode")
+
+	hotKeysThreshold = flag.Float64("hotkeys.threshold", 0, "Request rate (per second) that makes key hot, 0 disables hot keys detection")
+	hotKeysWindow    = flag.Duration("hotkeys.window", 10*time.Second, "Period over which request rate of keys is measured")
+	hotKeysCapacity  = flag.Int("hotkeys.capacity", 100, "Number of the most frequent keys tracked by node")
+	hotKeysReplicas  = flag.Int("hotkeys.replicas", 3, "Number of nodes (key owner and its successors) serving read requests of hot key")
+
+	hedgePercentile  = flag.Float64("hedge.percentile", 0, "Percentile of forwarded requests latency after which hedged request is sent to another replica, e.g. 0.95, 0 disables hedging")
+	hedgeSamples     = flag.Int("hedge.samples", 1000, "Number of the latest latencies percentile is calculated from")
+	hedgeMinDelay    = flag.Duration("hedge.min-delay", 5*time.Millisecond, "Minimum delay before hedged request is sent")
+	hedgeMaxDelay    = flag.Duration("hedge.max-delay", time.Second, "Maximum delay before hedged request is sent, it's used until enough latencies are observed")
+	hedgeBudget      = flag.Float64("hedge.budget", 0.1, "Maximum ratio of hedged requests to forwarded requests")
+	hedgeBudgetBurst = flag.Int("hedge.budget-burst", 10, "Number of hedged requests that could be sent at once")
+
+	cacheMaxBytes             = flag.Int64("cache.max-bytes", 0, "Maximum size of cached backend responses, 0 disables response cache")
+	cacheMaxEntryBytes        = flag.Int64("cache.max-entry-bytes", 1<<20, "Maximum size of single cached backend response")
+	cacheStaleWhileRevalidate = flag.Duration("cache.stale-while-revalidate", 0, "How long stale response is served while it's revalidated, if response has no stale-while-revalidate directive")
 
-	discoveryJSONFile = flag.String("discovery.json.file", "", "Discovery hosts from static file")
+	bootstrapAttempts        = flag.Int("bootstrap.attempts", 0, "Maximum number of ringpop bootstrap attempts, 0 means attempts aren't limited")
+	bootstrapInitialBackoff  = flag.Duration("bootstrap.initial-backoff", time.Second, "Delay after the first failed bootstrap attempt, it's doubled after every next one")
+	bootstrapMaxBackoff      = flag.Duration("bootstrap.max-backoff", 30*time.Second, "Maximum delay between bootstrap attempts")
+	bootstrapMaxJoinDuration = flag.Duration("bootstrap.max-join-duration", 0, "Maximum duration of single bootstrap attempt, 0 means ringpop's default (2m)")
+	bootstrapSingleNode      = flag.Bool("bootstrap.single-node", false, "Start as single-node ring when all bootstrap attempts failed")
+
+	healthTimeout   = flag.Duration("health.timeout", time.Second, "Timeout of readiness and liveness checks")
+	readyMinMembers = flag.Int("ready.min-members", 1, "Minimum number of reachable ring members (including this node) for node to be ready")
+
+	shutdownLeaveDelay = flag.Duration("shutdown.leave-delay", 2*time.Second, "How long node keeps serving after it left the ring on SIGTERM, so peers learn about it")
+	shutdownTimeout    = flag.Duration("shutdown.timeout", 30*time.Second, "Maximum time of draining in-flight requests on SIGTERM")
+
+	discoveryRefreshInterval = flag.Duration("discovery.refresh-interval", 30*time.Second, "Period between discovery queries, discovered hosts unknown to the ring are joined, 0 disables refreshing")
+	rejoinInterval           = flag.Duration("rejoin.interval", 30*time.Second, "Deprecated, the same as --discovery.refresh-interval")
+
+	discoveryMode = flag.String("discovery.mode", "", "How several discovery providers are combined: union (hosts of all providers) or fallback (hosts of the first provider with any, the static file is the last)")
+
+	discoveryJSONFile           = flag.String("discovery.json.file", "", "Discovery hosts from JSON or YAML (by .yaml or .yml extension) file, entries are addresses or objects with address, weight and labels")
+	discoveryJSONReloadInterval = flag.Duration("discovery.json.reload-interval", 2*time.Second, "Period between checks of hosts file changes, 0 disables reloading")
 
 	discoveryDNSHost     = flag.String("discovery.dns.host", "", "Discovery hosts from DNS by hostname")
 	discoveryDNSHostPort = flag.Int("discovery.dns.port", 0, "Ringpop port that will be added to discovered hosts from DNS")
+	discoveryDNSSRV      = flag.String("discovery.dns.srv", "", "Discovery hosts from DNS SRV records by name, e.g. _ringpop._tcp.ringpop.default.svc.cluster.local")
+
+	discoveryKubernetesSelector   = flag.String("discovery.kubernetes.selector", "", "Discovery hosts from Kubernetes EndpointSlices matching label selector, e.g. kubernetes.io/service-name=ringpop")
+	discoveryKubernetesNamespace  = flag.String("discovery.kubernetes.namespace", "", "Namespace of Kubernetes EndpointSlices, namespace of pod by default")
+	discoveryKubernetesPortName   = flag.String("discovery.kubernetes.port-name", "", "Name of ringpop port in Kubernetes EndpointSlices, could be empty if slices have the only port")
+	discoveryKubernetesKubeconfig = flag.String("discovery.kubernetes.kubeconfig", "", "Path to kubeconfig file, in-cluster config of service account is used by default")
+	discoveryKubernetesNotReady   = flag.Bool("discovery.kubernetes.not-ready", false, "Discovery endpoints of Kubernetes pods that aren't ready too")
+
+	discoveryConsulService    = flag.String("discovery.consul.service", "", "Discovery hosts from instances of Consul service passing health checks")
+	discoveryConsulAddress    = flag.String("discovery.consul.address", "http://127.0.0.1:8500", "Address of Consul agent")
+	discoveryConsulTag        = flag.String("discovery.consul.tag", "", "Tag of Consul service instances, e.g. ringpop")
+	discoveryConsulDatacenter = flag.String("discovery.consul.datacenter", "", "Datacenter of Consul service, datacenter of agent by default")
+	discoveryConsulToken      = os.Getenv("CONSUL_HTTP_TOKEN")
+
+	discoveryEtcdEndpoints = flag.String("discovery.etcd.endpoints", "", "Discovery hosts registered in etcd, comma-separated endpoints of etcd v3 JSON gateway, e.g. http://127.0.0.1:2379")
+	discoveryEtcdPrefix    = flag.String("discovery.etcd.prefix", "/ringpop/members/", "Prefix of etcd keys with addresses of ring members")
+	discoveryEtcdTTL       = flag.Duration("discovery.etcd.ttl", 10*time.Second, "TTL of etcd lease of node registration, node disappears after it when it's gone without deregistration")
+	discoveryEtcdRegister  = flag.Bool("discovery.etcd.register", true, "Register this node in etcd until shutdown")
 
 	// ringpopPeerIP is required to identify current instance in the ring.
 	// For example, when instances will be discovered from DNS records, it will be something like this:
@@ -44,7 +163,25 @@ func main() {
 	l.Level = logrus.Level(*logLevel)
 	logger := bark.NewLoggerFromLogrus(logrus.StandardLogger())
 
-	backendProxy, err := backend.New(*backendURL, logger)
+	// Context is canceled on SIGTERM, signals are caught from the very start to interrupt bootstrap retries
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+		sig := <-signals
+		logger.Infof("Received %s, shutting down...", sig)
+		stop()
+	}()
+
+	if *backendH2C {
+		if unsupported := setFlags("backend.idle-conn.timeout", "backend.max-idle-conns", "backend.max-idle-conns-per-host",
+			"backend.max-conns-per-host", "backend.http2"); len(unsupported) > 0 {
+			logger.Fatalf("flags aren't supported with --backend.h2c: %s", strings.Join(unsupported, ", "))
+		}
+	}
+
+	backendProxy, err := backend.New(*backendURL, backendTransportConfig(), logger)
 	if err != nil {
 		logger.Fatalf("unable to create backend reverse backendProxy: %v", err)
 	}
@@ -65,60 +202,359 @@ func main() {
 		logger.Fatalf("unable to create Ringpop: %v", err)
 	}
 
+	if *lookupMode != lookupModeConsistent && *lookupMode != lookupModeBoundedLoad {
+		logger.Fatalf("unknown lookup mode: %s", *lookupMode)
+	}
+
+	// Membership must be tracked before bootstrap to see all changes
+	members := ring.NewMembership(rp)
+
+	if *lookupWeighted {
+		if *lookupAlgorithm != ring.AlgorithmRingpop && *lookupAlgorithm != ring.AlgorithmRing {
+			logger.Fatalf("--lookup.weighted conflicts with --lookup.algorithm=%s", *lookupAlgorithm)
+		}
+		*lookupAlgorithm = ring.AlgorithmRing
+	}
+
+	if len(setFlags("rejoin.interval")) > 0 {
+		if len(setFlags("discovery.refresh-interval")) > 0 && *discoveryRefreshInterval != *rejoinInterval {
+			logger.Fatalf("--rejoin.interval conflicts with --discovery.refresh-interval=%v", *discoveryRefreshInterval)
+		}
+		*discoveryRefreshInterval = *rejoinInterval
+	}
+
+	// Selector of ringpop algorithm is used by /debug/ring only, keys are looked up by ringpop itself
+	selector, err := ring.NewSelector(*lookupAlgorithm, members)
+	if err != nil {
+		logger.Fatalf("unable to create node selector: %v", err)
+	}
+	if *ringpopWeight < 1 || *ringpopWeight > ring.MaxWeight {
+		logger.Fatalf("--ringpop.weight must be from 1 to %d", ring.MaxWeight)
+	}
+
+	var resolveOptions []ring.ResolveOption
+	if *lookupAlgorithm != ring.AlgorithmRingpop {
+		resolveOptions = append(resolveOptions, ring.WithSelector(selector))
+	} else {
+		if *ringpopWeight != 1 {
+			logger.Fatalf("--ringpop.weight has no effect with ringpop lookup algorithm, use one of: %s", strings.Join(ring.Algorithms(), ", "))
+		}
+
+		// Weights could be discovered (e.g. in hosts file) or set by other nodes
+		var warnWeights sync.Once
+		members.OnChange(func() {
+			for _, member := range members.ReachableMembers() {
+				if member.Weight() != 1 {
+					warnWeights.Do(func() {
+						logger.Warnf("Member %s has weight %d, weights are ignored by ringpop lookup algorithm", member.Address, member.Weight())
+					})
+					return
+				}
+			}
+		})
+	}
+
+	var routes *route.Table
+	if *routesFile != "" {
+		if routes, err = route.Load(*routesFile); err != nil {
+			logger.Fatalf("unable to load routes: %v", err)
+		}
+	}
+
+	// Ring key is internal, backend doesn't see it
+	var backendHandler http.Handler = ring.StripKeyHeader(backendProxy)
+
+	breakerConfig := circuitBreakerConfig()
+	if breakerConfig.Enabled() {
+		backendHandler = breaker.Handler(breaker.New("backend", breakerConfig), backendHandler)
+	}
+
+	if *concurrencyMaxLimit > 0 {
+		backendLimiter := limiter.New("backend", limiter.Config{
+			InitialLimit:     *concurrencyInitialLimit,
+			MinLimit:         *concurrencyMinLimit,
+			MaxLimit:         *concurrencyMaxLimit,
+			BackoffRatio:     *concurrencyBackoffRatio,
+			LatencyThreshold: *concurrencyLatencyThreshold,
+			MaxQueue:         *concurrencyMaxQueue,
+			QueueTimeout:     *concurrencyQueueTimeout,
+		})
+		backendHandler = limiter.Handler(backendLimiter, *concurrencyPriorityHeader, *concurrencyShedStatus, backendHandler)
+	}
+
+	// Identical requests of the same key meet on the key owner
+	backendHandler = coalesce.Handler(routes, backendHandler)
+
+	// Only key owner caches responses, so purge on the owner doesn't leave stale copies on nodes
+	// serving the key now and then (hot keys, overloaded owner or owner with unhealthy backend)
+	var responseCache *cache.Cache
+	if *cacheMaxBytes > 0 {
+		responseCache = cache.New(cache.Config{
+			MaxBytes:             *cacheMaxBytes,
+			MaxEntryBytes:        *cacheMaxEntryBytes,
+			StaleWhileRevalidate: *cacheStaleWhileRevalidate,
+		})
+		backendHandler = ring.OwnerHandler(rp, cache.Handler(responseCache, backendHandler), backendHandler, resolveOptions...)
+	}
+
+	// Requests of the same key are limited by their owner, except requests served by other nodes
+	// (hot keys, overloaded owner, owner with unhealthy backend): every node limits them on its own
+	backendHandler = ratelimit.Handler(ratelimit.NewLimiter(), routes, ring.RequestKey, backendHandler)
+
+	var hotKeys *hotkey.Tracker
+	if *hotKeysThreshold > 0 {
+		hotKeys = hotkey.NewTracker(hotkey.Config{
+			Capacity:  *hotKeysCapacity,
+			Window:    *hotKeysWindow,
+			Threshold: *hotKeysThreshold,
+			// Owner sees only part of requests when they are spread among replicas
+			CoolDownRatio: 1 / float64(*hotKeysReplicas),
+		})
+		backendHandler = hotkey.Handler(hotKeys, ring.RequestKey, backendHandler)
+	}
+
+	// Local requests and requests forwarded by peers are drained on shutdown
+	drainTracker := drain.New()
+	backendHandler = drain.Handler(drainTracker, backendHandler)
+
 	logger.Info("Running ringpop server...")
-	ringpopServer := ring.NewServer(ch, backendProxy, logger)
+	ringpopServer := ring.NewServer(ch, backendHandler, logger)
 
 	if err := ringpopServer.ListenAndServe(*ringpopListenOn); err != nil {
 		logger.Fatalf("unable to listen on given addr: %v", err)
 	}
 	logger.Info("...OK")
 
-	discoveryBuilder := discovery.NewProviderBuilder(logger)
+	discoveryBuilder := discovery.NewProviderBuilder(logger).WithMode(*discoveryMode)
 	if *discoveryJSONFile != "" {
-		discoveryBuilder.WithJSONFileDiscovery(*discoveryJSONFile)
+		discoveryBuilder.WithJSONFileDiscovery(*discoveryJSONFile).WithFileReloadInterval(*discoveryJSONReloadInterval)
 	}
-	if *discoveryDNSHost != "" && *discoveryDNSHostPort != 0 {
+	if *discoveryDNSHost != "" || *discoveryDNSHostPort != 0 {
 		discoveryBuilder.WithDNSDiscovery(*discoveryDNSHost, *discoveryDNSHostPort)
 	}
+	if *discoveryDNSSRV != "" {
+		discoveryBuilder.WithDNSSRVDiscovery(*discoveryDNSSRV)
+	}
+	if *discoveryKubernetesSelector != "" {
+		discoveryBuilder.WithKubernetesDiscovery(discovery.KubernetesConfig{
+			Kubeconfig:    *discoveryKubernetesKubeconfig,
+			Namespace:     *discoveryKubernetesNamespace,
+			LabelSelector: *discoveryKubernetesSelector,
+			PortName:      *discoveryKubernetesPortName,
+			NotReady:      *discoveryKubernetesNotReady,
+		})
+	}
+	if *discoveryConsulService != "" {
+		discoveryBuilder.WithConsulDiscovery(discovery.ConsulConfig{
+			Address:    *discoveryConsulAddress,
+			Service:    *discoveryConsulService,
+			Tag:        *discoveryConsulTag,
+			Datacenter: *discoveryConsulDatacenter,
+			Token:      discoveryConsulToken,
+		})
+	}
+	if *discoveryEtcdEndpoints != "" {
+		etcdConfig := discovery.EtcdConfig{
+			Endpoints: strings.Split(*discoveryEtcdEndpoints, ","),
+			Prefix:    *discoveryEtcdPrefix,
+			TTL:       *discoveryEtcdTTL,
+		}
+		if *discoveryEtcdRegister {
+			// Other nodes would join themselves by loopback or unspecified address
+			if ip := net.ParseIP(ringpopPeerIP); ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+				logger.Fatalf("unable to register %s in etcd, set RINGPOP_PEER_IP to address reachable by other nodes or disable --discovery.etcd.register", ringpopPeerIP)
+			}
+			etcdConfig.Address = net.JoinHostPort(ringpopPeerIP, ringpopPeerPort)
+		}
+		discoveryBuilder.WithEtcdDiscovery(etcdConfig)
+	}
 
 	discoveryProvider, err := discoveryBuilder.Build()
 	if err != nil {
 		logger.Fatalf("unable to create discovery provider: %v", err)
 	}
 
-	go func() {
-		requestForwarder := ring.NewForwarder(rp, logger)
+	var requestForwarder ring.Forwarder = ring.NewForwarder(rp, ch, logger)
+	if breakerConfig.Enabled() {
+		nodeBreakers := breaker.NewGroup("node:", breakerConfig)
+		// Breakers of nodes removed from the ring are dropped, so metric series don't pile up
+		members.OnChange(func() {
+			var nodes []string
+			for _, member := range members.Members() {
+				nodes = append(nodes, member.Address)
+			}
+			nodeBreakers.Retain(nodes...)
+		})
+		requestForwarder = ring.NewCircuitBreakingForwarder(requestForwarder, nodeBreakers)
+	}
+
+	// Transparent front HTTP server
+	httpServer := ringhttp.NewServer(rp, requestForwarder, backendHandler, logger)
+	httpServer.WithRoutes(routes)
+	httpServer.WithResolveOptions(resolveOptions...)
+	if *lookupMode == lookupModeBoundedLoad {
+		httpServer.WithBoundedLoad(ring.NewLoadTracker(), *lookupBoundedLoadEpsilon)
+	}
+	if hotKeys != nil {
+		httpServer.WithHotKeys(hotKeys, *hotKeysReplicas)
+	}
+	if *hedgePercentile > 0 {
+		httpServer.WithHedging(hedge.New(hedge.Config{
+			Percentile:  *hedgePercentile,
+			Samples:     *hedgeSamples,
+			MinDelay:    *hedgeMinDelay,
+			MaxDelay:    *hedgeMaxDelay,
+			Budget:      *hedgeBudget,
+			BudgetBurst: *hedgeBudgetBurst,
+		}))
+	}
 
+	frontSrv := &http.Server{Addr: *httpListenOn, Handler: http.HandlerFunc(httpServer.Handle)}
+	go func() {
 		logger.Infof("Running HTTP reverse proxy server on %s for backend %s...", *httpListenOn, *backendURL)
-		// Transparent front HTTP server
-		httpServer := ringhttp.NewServer(rp, requestForwarder, backendProxy, logger)
 
-		http.HandleFunc("/", httpServer.Handle)
-		if err := http.ListenAndServe(*httpListenOn, nil); err != nil {
+		if err := frontSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("unable to listen on %s: %s", *httpListenOn, err)
 		}
-
-		logger.Info("...OK")
 	}()
 
+	var healthChecker *backend.HealthChecker
+	if *backendHealthPath != "" {
+		healthChecker = backend.NewHealthChecker(backendProxy, backend.HealthCheckConfig{
+			Path:               *backendHealthPath,
+			Interval:           *backendHealthInterval,
+			Timeout:            *backendHealthTimeout,
+			HealthyThreshold:   *backendHealthHealthyThreshold,
+			UnhealthyThreshold: *backendHealthUnhealthyThreshold,
+		}, logger)
+	}
+
+	readiness := health.New(*healthTimeout).
+		Add("ringpop", ring.ReadyCheck(rp)).
+		Add("members", ring.MembersCheck(members, *readyMinMembers)).
+		Add("draining", func(context.Context) error {
+			if drainTracker.Draining() {
+				return errDraining
+			}
+			return nil
+		})
+	if healthChecker != nil {
+		readiness.Add("backend", func(context.Context) error {
+			if !healthChecker.Healthy() {
+				return errBackendUnhealthy
+			}
+			return nil
+		})
+	}
+	liveness := health.New(*healthTimeout).Add("ringpop", ring.LiveCheck(rp, ch))
+
+	debugMux := http.NewServeMux()
+	debugSrv := &http.Server{Addr: *debugListenOn, Handler: debugMux}
 	go func() {
-		debugSrv := http.NewServeMux()
-		debugSrv.Handle(metrics.MetricsPath, metrics.Handler())
+		debugMux.Handle(metrics.MetricsPath, metrics.Handler())
+		debugMux.Handle(health.ReadyPath, health.Handler(readiness))
+		debugMux.Handle(health.LivePath, health.Handler(liveness))
+		debugMux.Handle("/debug/members", ring.MembersHandler(rp, members))
+		debugMux.Handle("/debug/whoami", ring.WhoAmIHandler(rp))
+		debugMux.Handle("/debug/checksum", ring.ChecksumHandler(rp))
+		debugMux.Handle("/debug/lookup", ring.LookupHandler(rp, resolveOptions...))
+		debugMux.HandleFunc("/debug/explain", httpServer.Explain)
+		if hotKeys != nil {
+			debugMux.Handle("/debug/hotkeys", hotkey.DebugHandler(hotKeys))
+		}
+		if responseCache != nil {
+			debugMux.Handle("/debug/cache", cache.PurgeHandler(responseCache))
+		}
+		debugMux.Handle("/debug/ring", ring.SelectorHandler(selector))
 
 		logger.Infof("Running debug HTTP server on %s...", *debugListenOn)
 
-		if err := http.ListenAndServe(*debugListenOn, debugSrv); err != nil {
+		if err := debugSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("unable to listen on %s: %s", *debugListenOn, err)
 		}
 	}()
 
 	logger.Infof("Bootstrapping ringpop on %s...", *ringpopListenOn)
-	if err := ring.BootstrapRingpop(rp, discoveryProvider); err != nil {
+	if err := ring.BootstrapRingpopWithRetry(ctx, rp, discoveryProvider, ring.BootstrapConfig{
+		Attempts:        *bootstrapAttempts,
+		InitialBackoff:  *bootstrapInitialBackoff,
+		MaxBackoff:      *bootstrapMaxBackoff,
+		MaxJoinDuration: *bootstrapMaxJoinDuration,
+		SingleNode:      *bootstrapSingleNode,
+	}, logger); err != nil {
+		if ctx.Err() != nil {
+			gracefulShutdown(logger, rp, ch, discoveryProvider, drainTracker, frontSrv, debugSrv, *shutdownLeaveDelay, *shutdownTimeout)
+			return
+		}
 		logger.Fatalf("ringpop bootstrap failed: %v", err)
 	}
 	logger.Info("...OK")
 
-	select {}
+	if err := ring.SetWeight(rp, *ringpopWeight); err != nil {
+		logger.Fatalf("unable to share weight of node: %v", err)
+	}
+
+	if *discoveryRefreshInterval > 0 {
+		go ring.JoinDiscoveredHosts(ctx, rp, members, discoveryProvider, *discoveryRefreshInterval, *bootstrapMaxJoinDuration, logger)
+	} else if _, err := ring.ApplyDiscoveredLabels(rp, discoveryProvider, nil); err != nil {
+		logger.Errorf("unable to share discovered labels: %v", err)
+	}
+
+	if healthChecker != nil {
+		// Node with unhealthy backend stays in the ring, but other members stop routing keys to it
+		healthChecker.OnChange(func(healthy bool) {
+			if err := ring.SetBackendHealth(rp, healthy); err != nil {
+				logger.Errorf("unable to share backend health: %v", err)
+			}
+		})
+		if err := ring.SetBackendHealth(rp, healthChecker.Healthy()); err != nil {
+			logger.Errorf("unable to share backend health: %v", err)
+		}
+
+		healthChecker.Start()
+	}
+
+	<-ctx.Done()
+	gracefulShutdown(logger, rp, ch, discoveryProvider, drainTracker, frontSrv, debugSrv, *shutdownLeaveDelay, *shutdownTimeout)
+}
+
+func backendTransportConfig() backend.TransportConfig {
+	return backend.TransportConfig{
+		DialTimeout:           *backendDialTimeout,
+		KeepAlive:             *backendKeepAlive,
+		ResponseHeaderTimeout: *backendResponseHeaderTimeout,
+		IdleConnTimeout:       *backendIdleConnTimeout,
+		MaxIdleConns:          *backendMaxIdleConns,
+		MaxIdleConnsPerHost:   *backendMaxIdleConnsPerHost,
+		MaxConnsPerHost:       *backendMaxConnsPerHost,
+		HTTP2:                 *backendHTTP2,
+		H2C:                   *backendH2C,
+	}
+}
+
+// setFlags returns names of given flags which are set in command line
+func setFlags(names ...string) []string {
+	var set []string
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				set = append(set, "--"+name)
+			}
+		}
+	})
+
+	return set
+}
+
+func circuitBreakerConfig() breaker.Config {
+	return breaker.Config{
+		ConsecutiveFailures: *breakerConsecutiveFailures,
+		FailureRatio:        *breakerFailureRatio,
+		MinRequests:         *breakerMinRequests,
+		Window:              *breakerWindow,
+		OpenTimeout:         *breakerOpenTimeout,
+		HalfOpenRequests:    *breakerHalfOpenRequests,
+	}
 }
 
 func getRingpopPeerHostPort(ringpopPeerIP, pingpopListenOn string) (ip, port string, err error) {
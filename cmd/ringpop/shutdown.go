@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/drain"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/discovery"
+	"github.com/uber/tchannel-go"
+)
+
+// gracefulShutdown leaves the ring and drains in-flight requests:
+//  1. node is marked as draining (not ready);
+//  2. node evicts itself from the ring, peers stop routing keys to it once gossip spreads,
+//     discovery provider is stopped, so the node is deregistered when it registered itself;
+//  3. front HTTP server stops accepting connections and waits for local and forwarded requests;
+//  4. requests forwarded to this node by peers are drained;
+//  5. ringpop is destroyed and TChannel is closed.
+//
+// Steps 3 and 4 share the timeout.
+func gracefulShutdown(
+	logger bark.Logger,
+	rp *ringpop.Ringpop,
+	ch *tchannel.Channel,
+	provider discovery.DiscoverProvider,
+	tracker *drain.Tracker,
+	frontSrv, debugSrv *http.Server,
+	leaveDelay, timeout time.Duration,
+) {
+	tracker.Start()
+
+	logger.Info("Leaving the ring...")
+	err := rp.SelfEvict()
+	if stopper, ok := provider.(interface{ Stop() }); ok {
+		stopper.Stop()
+	}
+	if err != nil {
+		logger.Errorf("unable to leave the ring: %v", err)
+	} else {
+		// Requests of peers which haven't seen the eviction yet are still served
+		time.Sleep(leaveDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logger.Info("Draining in-flight requests...")
+	if err := frontSrv.Shutdown(ctx); err != nil {
+		logger.Errorf("unable to drain HTTP requests: %v", err)
+	}
+	if err := tracker.Wait(ctx); err != nil {
+		logger.Errorf("unable to drain requests of ring members, %d left: %v", tracker.InFlight(), err)
+	}
+
+	// Debug server is the last to go, metrics are available while node drains
+	debugSrv.Close()
+
+	rp.Destroy()
+	ch.Close()
+
+	logger.Info("...OK")
+}
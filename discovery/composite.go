@@ -0,0 +1,138 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+const (
+	// ModeUnion combines hosts of all providers
+	ModeUnion = "union"
+	// ModeFallback uses hosts of the first provider which discovered any, the next ones are fallback
+	ModeFallback = "fallback"
+)
+
+// CompositeProvider combines hosts of several providers.
+// Providers failing to discover hosts are skipped, error is returned only when all of them failed.
+// Changes of watching providers are propagated, Stop stops all providers.
+// Compatible with github.com/uber/ringpop-go/discovery.DiscoveryProvider interface
+type CompositeProvider struct {
+	providers []discovery.DiscoverProvider
+	fallback  bool
+	logger    bark.Logger
+
+	changed chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newCompositeProvider returns provider that queries given providers in order
+func newCompositeProvider(providers []discovery.DiscoverProvider, mode string, logger bark.Logger) *CompositeProvider {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &CompositeProvider{
+		providers: providers,
+		fallback:  mode == ModeFallback,
+		logger:    logger,
+		changed:   make(chan struct{}, 1),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for _, provider := range providers {
+		if watcher, ok := provider.(Watcher); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.forward(ctx, watcher.Changed())
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+
+	return p
+}
+
+// Hosts returns hosts of all providers (union mode) or of the first provider with any hosts (fallback mode)
+func (p *CompositeProvider) Hosts() ([]string, error) {
+	var hosts []string
+	var lastErr error
+	discovered := false
+	for _, provider := range p.providers {
+		providerHosts, err := provider.Hosts()
+		if err != nil {
+			p.logger.Warnf("Unable to discover hosts by %T: %v", provider, err)
+			lastErr = err
+			continue
+		}
+		discovered = true
+
+		hosts = append(hosts, providerHosts...)
+		if p.fallback && len(hosts) > 0 {
+			break
+		}
+	}
+
+	if !discovered {
+		return nil, lastErr
+	}
+
+	return dedupe(hosts), nil
+}
+
+// Labels returns labels of host from the first provider which knows them
+func (p *CompositeProvider) Labels(address string) (map[string]string, bool) {
+	for _, provider := range p.providers {
+		if labeler, ok := provider.(Labeler); ok {
+			if labels, ok := labeler.Labels(address); ok {
+				return labels, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Changed returns channel that receives value when hosts of any watching provider change
+func (p *CompositeProvider) Changed() <-chan struct{} {
+	return p.changed
+}
+
+// Stop stops propagation of changes and all providers which could be stopped
+func (p *CompositeProvider) Stop() {
+	p.cancel()
+	<-p.done
+
+	stopProviders(p.providers)
+}
+
+func (p *CompositeProvider) forward(ctx context.Context, changed <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+
+		select {
+		case p.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// stopProviders stops watching providers
+func stopProviders(providers []discovery.DiscoverProvider) {
+	for _, provider := range providers {
+		if stopper, ok := provider.(interface{ Stop() }); ok {
+			stopper.Stop()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+type staticProvider struct {
+	hosts []string
+	err   error
+}
+
+func (p *staticProvider) Hosts() ([]string, error) {
+	return p.hosts, p.err
+}
+
+type watchingProvider struct {
+	staticProvider
+	changes *hostSet
+	stopped bool
+}
+
+func (p *watchingProvider) Changed() <-chan struct{} {
+	return p.changes.changed
+}
+
+func (p *watchingProvider) Stop() {
+	p.stopped = true
+}
+
+func TestCompositeProvider(t *testing.T) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+	failing := &staticProvider{err: errors.New("unavailable")}
+	empty := &staticProvider{hosts: []string{}}
+	watching := &watchingProvider{
+		staticProvider: staticProvider{hosts: []string{"10.0.0.2:5000", "10.0.0.1:5000"}},
+		changes:        newHostSet(),
+	}
+	seeds := &staticProvider{hosts: []string{"10.0.0.1:5000", "10.0.0.3:5000"}}
+
+	for _, tc := range []struct {
+		mode      string
+		providers []discovery.DiscoverProvider
+		expected  []string
+	}{
+		{ModeUnion, []discovery.DiscoverProvider{failing, watching, seeds}, []string{"10.0.0.1:5000", "10.0.0.2:5000", "10.0.0.3:5000"}},
+		{ModeFallback, []discovery.DiscoverProvider{failing, empty, watching, seeds}, []string{"10.0.0.1:5000", "10.0.0.2:5000"}},
+		{ModeFallback, []discovery.DiscoverProvider{empty, seeds}, []string{"10.0.0.1:5000", "10.0.0.3:5000"}},
+	} {
+		p := newCompositeProvider(tc.providers, tc.mode, logger)
+		hosts, err := p.Hosts()
+		p.Stop()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(hosts, tc.expected) {
+			t.Fatalf("Unexpected hosts of %s mode: %v, expected: %v", tc.mode, hosts, tc.expected)
+		}
+	}
+
+	p := newCompositeProvider([]discovery.DiscoverProvider{failing, failing}, ModeUnion, logger)
+	if _, err := p.Hosts(); err == nil {
+		t.Fatal("Error is expected when all providers failed")
+	}
+	p.Stop()
+
+	p = newCompositeProvider([]discovery.DiscoverProvider{seeds, watching}, ModeUnion, logger)
+	watching.changes.set([]string{"10.0.0.4:5000"})
+	<-p.Changed()
+	p.Stop()
+	if !watching.stopped {
+		t.Fatal("Watching provider isn't stopped")
+	}
+}
+
+func TestProviderBuilderConflicts(t *testing.T) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+
+	if _, err := NewProviderBuilder(logger).
+		WithJSONFileDiscovery("hosts.json").
+		WithDNSDiscovery("ringpop", 5000).
+		Build(); err == nil {
+		t.Fatal("Error is expected when several providers are configured without mode")
+	}
+
+	if _, err := NewProviderBuilder(logger).WithDNSDiscovery("ringpop", 0).Build(); err == nil {
+		t.Fatal("Error is expected when DNS port is missing")
+	}
+
+	if _, err := NewProviderBuilder(logger).WithJSONFileDiscovery("hosts.json").WithMode("random").Build(); err == nil {
+		t.Fatal("Error is expected for unknown mode")
+	}
+
+	p, err := NewProviderBuilder(logger).
+		WithJSONFileDiscovery("hosts.json").
+		WithDNSDiscovery("ringpop", 5000).
+		WithMode(ModeFallback).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := p.(*CompositeProvider); !ok {
+		t.Fatalf("Unexpected provider: %T", p)
+	}
+}
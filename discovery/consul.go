@@ -0,0 +1,212 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-common/bark"
+)
+
+const (
+	// consulWait limits blocking query, Consul responds with unchanged instances after it
+	consulWait = 5 * time.Minute
+
+	consulMinBackoff = time.Second
+	consulMaxBackoff = 30 * time.Second
+
+	// consulRequestTimeout limits non-blocking queries, blocking ones get wait time on top of it
+	consulRequestTimeout = 5 * time.Second
+)
+
+// ConsulConfig describes which instances of Consul service are ring members
+type ConsulConfig struct {
+	// Address of Consul agent, e.g. http://127.0.0.1:8500
+	Address string
+	// Service is a name of registered service
+	Service string
+	// Tag filters instances of service, e.g. ringpop
+	Tag string
+	// Datacenter of service, datacenter of agent is used when it's empty
+	Datacenter string
+	// Token is ACL token with read access to service
+	Token string
+}
+
+// ConsulProvider discovers instances of Consul service passing health checks.
+// Instances are watched in background by blocking queries, so hosts are always up to date.
+// Compatible with github.com/uber/ringpop-go/discovery.DiscoveryProvider interface
+type ConsulProvider struct {
+	config ConsulConfig
+	client *http.Client
+	logger bark.Logger
+
+	hosts *hostSet
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// consulServiceEntry is a part of /v1/health/service response used by provider
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// newConsulProvider returns provider that watches instances of service until Stop is called
+func newConsulProvider(cfg ConsulConfig, logger bark.Logger) *ConsulProvider {
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ConsulProvider{
+		config: cfg,
+		// Timeouts of queries are set by query
+		client: &http.Client{},
+		logger: logger,
+		hosts:  newHostSet(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx)
+
+	return p
+}
+
+// Hosts returns ringpop addresses of healthy instances
+func (p *ConsulProvider) Hosts() ([]string, error) {
+	if hosts, synced := p.hosts.get(); synced {
+		return hosts, nil
+	}
+
+	// Watch hasn't received instances yet
+	hosts, _, err := p.query(context.Background(), 0)
+	return hosts, err
+}
+
+// Changed returns channel that receives value when discovered hosts change
+func (p *ConsulProvider) Changed() <-chan struct{} {
+	return p.hosts.changed
+}
+
+// Stop stops watching instances
+func (p *ConsulProvider) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+func (p *ConsulProvider) run(ctx context.Context) {
+	defer close(p.done)
+
+	var index uint64
+	backoff := consulMinBackoff
+	for {
+		hosts, newIndex, err := p.query(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			p.logger.Warnf("Unable to query Consul service %s, retrying in %v: %v", p.config.Service, backoff, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			if backoff *= 2; backoff > consulMaxBackoff {
+				backoff = consulMaxBackoff
+			}
+			continue
+		}
+		backoff = consulMinBackoff
+
+		// Index going backwards means Consul state was reset, see "Blocking Queries" of Consul docs.
+		// Index must be greater than 0, otherwise query doesn't block.
+		switch {
+		case newIndex < index:
+			index = 0
+		case newIndex == 0:
+			index = 1
+		default:
+			index = newIndex
+		}
+
+		if p.hosts.set(hosts) {
+			p.logger.Infof("Discovered endpoints in Consul: %s", strings.Join(hosts, ", "))
+		}
+	}
+}
+
+// query returns healthy instances, it blocks until instances change after given index (if it isn't 0)
+func (p *ConsulProvider) query(ctx context.Context, index uint64) ([]string, uint64, error) {
+	query := url.Values{}
+	query.Set("passing", "true")
+	if p.config.Tag != "" {
+		query.Set("tag", p.config.Tag)
+	}
+	if p.config.Datacenter != "" {
+		query.Set("dc", p.config.Datacenter)
+	}
+	timeout := consulRequestTimeout
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWait.String())
+		// Consul adds up to wait/16 of jitter to wait time
+		timeout += consulWait + consulWait/16
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		p.config.Address+"/v1/health/service/"+url.PathEscape(p.config.Service)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.config.Token != "" {
+		req.Header.Set("X-Consul-Token", p.config.Token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, 0, fmt.Errorf("Consul responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, err
+	}
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	hosts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Service address is empty when it's the same as address of node
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		hosts = append(hosts, net.JoinHostPort(address, strconv.Itoa(entry.Service.Port)))
+	}
+
+	return dedupe(hosts), newIndex, nil
+}
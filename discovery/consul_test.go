@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+)
+
+func TestConsulProvider(t *testing.T) {
+	// Stand-in of Consul agent, blocking query with index 1 returns when the second instance passes checks
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/v1/health/service/api" || query.Get("passing") != "true" || query.Get("tag") != "ringpop" ||
+			r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch query.Get("index") {
+		case "":
+			w.Header().Set("X-Consul-Index", "1")
+			fmt.Fprint(w, `[{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 5000}}]`)
+		case "1":
+			w.Header().Set("X-Consul-Index", "2")
+			fmt.Fprint(w, `[
+				{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 5000}},
+				{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "10.0.1.2", "Port": 5001}}
+			]`)
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	p := newConsulProvider(ConsulConfig{
+		Address: srv.URL,
+		Service: "api",
+		Tag:     "ringpop",
+		Token:   "secret",
+	}, bark.NewLoggerFromLogrus(logrus.New()))
+	defer p.Stop()
+
+	expected := []string{"10.0.0.1:5000", "10.0.1.2:5001"}
+	for i := 0; ; i++ {
+		hosts, err := p.Hosts()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reflect.DeepEqual(hosts, expected) {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("Unexpected hosts: %v, expected: %v", hosts, expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-p.Changed():
+	default:
+		t.Fatal("Change of hosts isn't notified")
+	}
+}
@@ -1,37 +1,73 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/ozontech/http-ringpop/ring"
 	"github.com/uber-common/bark"
 	"github.com/uber/ringpop-go/discovery"
 )
 
+// dnsResolver is implemented by net.Resolver
+type dnsResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
 // DNSProvider returns a list of host hosts
 // Compatible with github.com/uber/ringpop-go/discovery.DiscoveryProvider interface
 type DNSProvider struct {
 	host   string
 	port   int
+	srv    bool
 	logger bark.Logger
+
+	resolver dnsResolver
+
+	// weights of SRV targets from the last lookup scaled to ring weights
+	mu      sync.RWMutex
+	weights map[string]int
 }
 
 // newDNSProvider returns new providers that discovers host endpoints
 // by hostname using DNS records
 func newDNSProvider(host string, port int, logger bark.Logger) discovery.DiscoverProvider {
 	provider := &DNSProvider{
-		host:   host,
-		port:   port,
-		logger: logger,
+		host:     host,
+		port:     port,
+		logger:   logger,
+		resolver: net.DefaultResolver,
+	}
+	return provider
+}
+
+// newDNSSRVProvider returns new provider that discovers host endpoints
+// by SRV records of name, e.g. _ringpop._tcp.ringpop.default.svc.cluster.local.
+// Ports come from SRV records, targets are resolved to IP addresses.
+// Weights of records are discovered as weights of nodes, they are scaled so the greatest one is ring.MaxWeight.
+func newDNSSRVProvider(name string, logger bark.Logger) discovery.DiscoverProvider {
+	provider := &DNSProvider{
+		host:     name,
+		srv:      true,
+		logger:   logger,
+		resolver: net.DefaultResolver,
 	}
 	return provider
 }
 
 func (k *DNSProvider) Hosts() ([]string, error) {
+	if k.srv {
+		return k.srvHosts()
+	}
+
 	k.logger.Infof("Discovering hosts from DNS by hostname: %s...", k.host)
 
-	addrs, err := net.LookupHost(k.host)
+	addrs, err := k.resolver.LookupHost(context.Background(), k.host)
 	if err != nil {
 		if _, ok := err.(*net.DNSError); ok {
 			return []string{}, nil
@@ -48,3 +84,77 @@ func (k *DNSProvider) Hosts() ([]string, error) {
 
 	return addrs, nil
 }
+
+// srvHosts returns endpoints of SRV records with the lowest priority, records with higher priority
+// values are fallback used only when targets of lower ones can't be resolved.
+// Weights of records are scaled and kept for Labels.
+func (k *DNSProvider) srvHosts() ([]string, error) {
+	k.logger.Infof("Discovering hosts from DNS by SRV records: %s...", k.host)
+
+	_, records, err := k.resolver.LookupSRV(context.Background(), "", "", k.host)
+	if err != nil {
+		if _, ok := err.(*net.DNSError); ok {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	addrs := []string{}
+	weights := make(map[string]int)
+	var maxWeight int
+	for i, record := range records {
+		if i > 0 && record.Priority != records[i-1].Priority && len(addrs) > 0 {
+			break
+		}
+
+		ips, err := k.resolver.LookupHost(context.Background(), record.Target)
+		if err != nil {
+			k.logger.Warnf("Unable to resolve target %s of SRV record: %v", record.Target, err)
+			continue
+		}
+
+		for _, ip := range ips {
+			addr := net.JoinHostPort(ip, strconv.Itoa(int(record.Port)))
+			addrs = append(addrs, addr)
+			weights[addr] = int(record.Weight)
+		}
+		if int(record.Weight) > maxWeight {
+			maxWeight = int(record.Weight)
+		}
+	}
+
+	// SRV weights are up to 65535, they are scaled to ring weights keeping their ratios
+	for addr, weight := range weights {
+		if weight > 0 {
+			weights[addr] = (weight*ring.MaxWeight + maxWeight - 1) / maxWeight
+		}
+	}
+
+	k.mu.Lock()
+	k.weights = weights
+	k.mu.Unlock()
+
+	k.logger.Infof("Discovered endpoints: %s", strings.Join(addrs, ", "))
+
+	return addrs, nil
+}
+
+// Labels returns weight of SRV record of host, records with zero weight keep weight of node as it is.
+// False is returned when host isn't discovered by SRV records.
+func (k *DNSProvider) Labels(address string) (map[string]string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	weight, ok := k.weights[address]
+	if !ok {
+		return nil, false
+	}
+
+	labels := make(map[string]string, 1)
+	if weight > 0 {
+		labels[ring.LabelWeight] = strconv.Itoa(weight)
+	}
+
+	return labels, true
+}
@@ -0,0 +1,79 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+)
+
+type fakeResolver struct {
+	hosts map[string][]string
+	srv   []*net.SRV
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return append([]string(nil), addrs...), nil
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return name, r.srv, nil
+}
+
+func TestDNSSRVProvider(t *testing.T) {
+	resolver := &fakeResolver{
+		hosts: map[string][]string{
+			"a.ringpop.": {"10.0.0.1"},
+			"b.ringpop.": {"10.0.0.2"},
+			"backup.":    {"10.0.1.1"},
+		},
+		srv: []*net.SRV{
+			{Target: "a.ringpop.", Port: 5000, Priority: 10, Weight: 60},
+			{Target: "b.ringpop.", Port: 5001, Priority: 10, Weight: 40},
+			{Target: "backup.", Port: 5000, Priority: 20},
+		},
+	}
+
+	p := newDNSSRVProvider("_ringpop._tcp.ringpop.", bark.NewLoggerFromLogrus(logrus.New())).(*DNSProvider)
+	p.resolver = resolver
+
+	hosts, err := p.Hosts()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"10.0.0.1:5000", "10.0.0.2:5001"}; !reflect.DeepEqual(hosts, expected) {
+		t.Fatalf("Unexpected hosts: %v, expected: %v", hosts, expected)
+	}
+
+	if labels, ok := p.Labels("10.0.0.2:5001"); !ok || !reflect.DeepEqual(labels, map[string]string{"weight": "67"}) {
+		t.Fatalf("Unexpected labels: %v, %v", labels, ok)
+	}
+	if labels, ok := p.Labels("10.0.0.1:5000"); !ok || !reflect.DeepEqual(labels, map[string]string{"weight": "100"}) {
+		t.Fatalf("Unexpected labels of record with the greatest weight: %v, %v", labels, ok)
+	}
+	if _, ok := p.Labels("10.0.1.1:5000"); ok {
+		t.Fatal("Labels of host that isn't discovered are unexpected")
+	}
+
+	// Targets of lower priority can't be resolved, fallback is used
+	delete(resolver.hosts, "a.ringpop.")
+	delete(resolver.hosts, "b.ringpop.")
+	hosts, err = p.Hosts()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"10.0.1.1:5000"}; !reflect.DeepEqual(hosts, expected) {
+		t.Fatalf("Unexpected fallback hosts: %v, expected: %v", hosts, expected)
+	}
+	if labels, ok := p.Labels("10.0.1.1:5000"); !ok || len(labels) != 0 {
+		t.Fatalf("Unexpected labels of record with zero weight: %v, %v", labels, ok)
+	}
+}
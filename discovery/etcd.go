@@ -0,0 +1,418 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-common/bark"
+)
+
+const (
+	etcdMinBackoff = time.Second
+	etcdMaxBackoff = 30 * time.Second
+
+	// etcdRequestTimeout limits all requests to etcd except watch
+	etcdRequestTimeout = 5 * time.Second
+)
+
+// errLeaseExpired is returned when lease of registration isn't kept alive anymore
+var errLeaseExpired = errors.New("lease expired")
+
+// errCompacted is returned when watched revision is compacted, prefix is listed again
+var errCompacted = errors.New("watched revision is compacted")
+
+// EtcdConfig describes where ring members are registered in etcd
+type EtcdConfig struct {
+	// Endpoints of etcd v3 JSON gateway, e.g. http://127.0.0.1:2379
+	Endpoints []string
+	// Prefix of keys with addresses of ring members, e.g. /ringpop/members/
+	Prefix string
+	// Address of this node registered under prefix, empty value disables self-registration
+	Address string
+	// TTL of registration, node disappears after TTL when it's gone without deregistration
+	TTL time.Duration
+}
+
+// EtcdProvider discovers hosts registered under etcd prefix and registers this node there
+// with lease that is kept alive until Stop is called. Prefix is watched in background,
+// so hosts are always up to date.
+// Compatible with github.com/uber/ringpop-go/discovery.DiscoveryProvider interface
+type EtcdProvider struct {
+	config EtcdConfig
+	client *http.Client
+	logger bark.Logger
+
+	// members maps key to address, it's owned by watching goroutine
+	members map[string]string
+	hosts   *hostSet
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type etcdKeyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value,omitempty"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision int64 `json:"revision,string"`
+	} `json:"header"`
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Created bool `json:"created"`
+		// Canceled watch is the last response of stream, e.g. when watched revision is compacted
+		Canceled        bool   `json:"canceled"`
+		CancelReason    string `json:"cancel_reason"`
+		CompactRevision int64  `json:"compact_revision,string"`
+		Events          []struct {
+			// Type is omitted for PUT, it's the default value
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type etcdLeaseResponse struct {
+	ID  int64 `json:"ID,string"`
+	TTL int64 `json:"TTL,string"`
+}
+
+// newEtcdProvider returns provider that registers this node and watches prefix until Stop is called
+func newEtcdProvider(cfg EtcdConfig, logger bark.Logger) (*EtcdProvider, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("no etcd endpoints")
+	}
+	for i := range cfg.Endpoints {
+		cfg.Endpoints[i] = strings.TrimSuffix(cfg.Endpoints[i], "/")
+	}
+	if cfg.TTL < time.Second {
+		cfg.TTL = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &EtcdProvider{
+		config: cfg,
+		// Watch requests are long, other requests are limited by context
+		client:  &http.Client{},
+		logger:  logger,
+		members: make(map[string]string),
+		hosts:   newHostSet(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.done)
+
+		if cfg.Address == "" {
+			p.watchLoop(ctx)
+			return
+		}
+
+		registered := make(chan struct{})
+		go func() {
+			defer close(registered)
+			p.registerLoop(ctx)
+		}()
+		p.watchLoop(ctx)
+		<-registered
+	}()
+
+	return p, nil
+}
+
+// Hosts returns addresses registered under prefix
+func (p *EtcdProvider) Hosts() ([]string, error) {
+	if hosts, synced := p.hosts.get(); synced {
+		return hosts, nil
+	}
+
+	// Watch hasn't listed prefix yet
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	var resp etcdRangeResponse
+	if err := p.call(ctx, "/v3/kv/range", p.rangeRequest(), &resp); err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		hosts = append(hosts, string(kv.Value))
+	}
+
+	return dedupe(hosts), nil
+}
+
+// Changed returns channel that receives value when discovered hosts change
+func (p *EtcdProvider) Changed() <-chan struct{} {
+	return p.hosts.changed
+}
+
+// Stop stops watching and deregisters this node
+func (p *EtcdProvider) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+// registerLoop keeps registration of this node, lease is revoked when context is done
+func (p *EtcdProvider) registerLoop(ctx context.Context) {
+	backoff := etcdMinBackoff
+	for {
+		err := p.register(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.logger.Warnf("Unable to keep registration in etcd, retrying in %v: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > etcdMaxBackoff {
+			backoff = etcdMaxBackoff
+		}
+	}
+}
+
+// register puts address of this node with new lease and keeps the lease alive until error
+func (p *EtcdProvider) register(ctx context.Context) error {
+	var lease etcdLeaseResponse
+	if err := p.callWithTimeout(ctx, "/v3/lease/grant", map[string]interface{}{
+		"TTL": int64(p.config.TTL / time.Second),
+	}, &lease); err != nil {
+		return err
+	}
+	leaseID := strconv.FormatInt(lease.ID, 10)
+
+	// Deregistration must not be canceled together with the node
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+		defer cancel()
+
+		if err := p.call(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": leaseID}, nil); err != nil {
+			p.logger.Warnf("Unable to revoke etcd lease: %v", err)
+		}
+	}()
+
+	if err := p.callWithTimeout(ctx, "/v3/kv/put", map[string]interface{}{
+		"key":   []byte(p.config.Prefix + p.config.Address),
+		"value": []byte(p.config.Address),
+		"lease": leaseID,
+	}, nil); err != nil {
+		return err
+	}
+	p.logger.Infof("Registered %s in etcd under %s", p.config.Address, p.config.Prefix)
+
+	ticker := time.NewTicker(p.config.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		var keepalive struct {
+			Result etcdLeaseResponse `json:"result"`
+		}
+		if err := p.callWithTimeout(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": leaseID}, &keepalive); err != nil {
+			return err
+		}
+		if keepalive.Result.TTL <= 0 {
+			return errLeaseExpired
+		}
+	}
+}
+
+// watchLoop lists prefix and watches its changes until context is done
+func (p *EtcdProvider) watchLoop(ctx context.Context) {
+	backoff := etcdMinBackoff
+	for {
+		err := p.listAndWatch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == errCompacted {
+			backoff = etcdMinBackoff
+			continue
+		}
+		p.logger.Warnf("Unable to watch etcd prefix %s, retrying in %v: %v", p.config.Prefix, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > etcdMaxBackoff {
+			backoff = etcdMaxBackoff
+		}
+	}
+}
+
+func (p *EtcdProvider) listAndWatch(ctx context.Context) error {
+	var list etcdRangeResponse
+	if err := p.callWithTimeout(ctx, "/v3/kv/range", p.rangeRequest(), &list); err != nil {
+		return err
+	}
+
+	p.members = make(map[string]string, len(list.Kvs))
+	for _, kv := range list.Kvs {
+		p.members[string(kv.Key)] = string(kv.Value)
+	}
+	p.update()
+
+	request := p.rangeRequest()
+	request["start_revision"] = strconv.FormatInt(list.Header.Revision+1, 10)
+
+	body, err := p.stream(ctx, "/v3/watch", map[string]interface{}{"create_request": request})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var resp etcdWatchResponse
+		if err := decoder.Decode(&resp); err != nil {
+			if err == io.EOF {
+				return errors.New("watch stream is closed")
+			}
+			return err
+		}
+		if resp.Error != nil {
+			return errors.New(resp.Error.Message)
+		}
+		if resp.Result.CompactRevision > 0 {
+			return errCompacted
+		}
+		if resp.Result.Canceled {
+			return fmt.Errorf("watch is canceled: %s", resp.Result.CancelReason)
+		}
+
+		if len(resp.Result.Events) == 0 {
+			continue
+		}
+		for _, event := range resp.Result.Events {
+			if event.Type == "DELETE" {
+				delete(p.members, string(event.Kv.Key))
+			} else {
+				p.members[string(event.Kv.Key)] = string(event.Kv.Value)
+			}
+		}
+		p.update()
+	}
+}
+
+// update shares addresses of all members
+func (p *EtcdProvider) update() {
+	hosts := make([]string, 0, len(p.members))
+	for _, address := range p.members {
+		hosts = append(hosts, address)
+	}
+
+	hosts = dedupe(hosts)
+	if p.hosts.set(hosts) {
+		p.logger.Infof("Discovered endpoints in etcd: %s", strings.Join(hosts, ", "))
+	}
+}
+
+// rangeRequest selects all keys with prefix
+func (p *EtcdProvider) rangeRequest() map[string]interface{} {
+	return map[string]interface{}{
+		"key":       []byte(p.config.Prefix),
+		"range_end": prefixEnd([]byte(p.config.Prefix)),
+	}
+}
+
+// prefixEnd returns the first key after all keys with given prefix
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	// All keys are after prefix of 0xff bytes
+	return []byte{0}
+}
+
+func (p *EtcdProvider) callWithTimeout(ctx context.Context, path string, request, response interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	defer cancel()
+
+	return p.call(ctx, path, request, response)
+}
+
+// call makes request to the first available endpoint and decodes response
+func (p *EtcdProvider) call(ctx context.Context, path string, request, response interface{}) error {
+	body, err := p.stream(ctx, path, request)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if response == nil {
+		return nil
+	}
+
+	return json.NewDecoder(body).Decode(response)
+}
+
+// stream makes request to the first available endpoint and returns response body
+func (p *EtcdProvider) stream(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, endpoint := range p.config.Endpoints {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("etcd responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		return resp.Body, nil
+	}
+
+	return nil, lastErr
+}
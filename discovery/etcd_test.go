@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+)
+
+// fakeEtcd is a stand-in of etcd v3 JSON gateway
+type fakeEtcd struct {
+	mu      sync.Mutex
+	kvs     map[string]string
+	revoked bool
+
+	events chan etcdKeyValue
+	// compact cancels watch as etcd does when watched revision is compacted
+	compact chan struct{}
+}
+
+func (e *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value"`
+		Lease string `json:"lease"`
+		ID    string `json:"ID"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		fmt.Fprint(w, `{"ID": "42", "TTL": "1"}`)
+	case "/v3/lease/keepalive":
+		fmt.Fprint(w, `{"result": {"ID": "42", "TTL": "1"}}`)
+	case "/v3/lease/revoke":
+		e.revoked = true
+		e.kvs = map[string]string{}
+		fmt.Fprint(w, `{}`)
+	case "/v3/kv/put":
+		if req.Lease != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		e.kvs[string(req.Key)] = string(req.Value)
+		e.events <- etcdKeyValue{Key: req.Key, Value: req.Value}
+		fmt.Fprint(w, `{}`)
+	case "/v3/kv/range":
+		resp := etcdRangeResponse{}
+		resp.Header.Revision = 1
+		for key, value := range e.kvs {
+			resp.Kvs = append(resp.Kvs, etcdKeyValue{Key: []byte(key), Value: []byte(value)})
+		}
+		data, _ := json.Marshal(resp)
+		w.Write(data)
+	case "/v3/watch":
+		e.mu.Unlock()
+		defer e.mu.Lock()
+
+		fmt.Fprint(w, `{"result": {"created": true}}`)
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case kv := <-e.events:
+				data, _ := json.Marshal(map[string]interface{}{
+					"result": map[string]interface{}{"events": []interface{}{map[string]interface{}{"kv": kv}}},
+				})
+				w.Write(data)
+				w.(http.Flusher).Flush()
+			case <-e.compact:
+				fmt.Fprint(w, `{"result": {"canceled": true, "compact_revision": "2", "cancel_reason": "mvcc: required revision has been compacted"}}`)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func waitForHosts(t *testing.T, p *EtcdProvider, expected []string) {
+	for i := 0; ; i++ {
+		hosts, err := p.Hosts()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reflect.DeepEqual(hosts, expected) {
+			return
+		}
+		if i == 100 {
+			t.Fatalf("Unexpected hosts: %v, expected: %v", hosts, expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEtcdProvider(t *testing.T) {
+	etcd := &fakeEtcd{kvs: map[string]string{}, events: make(chan etcdKeyValue, 10), compact: make(chan struct{})}
+	srv := httptest.NewServer(etcd)
+	defer srv.Close()
+
+	p, err := newEtcdProvider(EtcdConfig{
+		// The first endpoint is unavailable
+		Endpoints: []string{"http://127.0.0.1:1", srv.URL},
+		Prefix:    "/ringpop/",
+		Address:   "10.0.0.1:5000",
+		TTL:       time.Second,
+	}, bark.NewLoggerFromLogrus(logrus.New()))
+	if err != nil {
+		t.Fatalf("Unable to create provider: %v", err)
+	}
+	defer p.Stop()
+
+	waitForHosts(t, p, []string{"10.0.0.1:5000"})
+
+	etcd.events <- etcdKeyValue{Key: []byte("/ringpop/10.0.0.2:5000"), Value: []byte("10.0.0.2:5000")}
+	waitForHosts(t, p, []string{"10.0.0.1:5000", "10.0.0.2:5000"})
+
+	// Change missed by compacted watch is seen by the next list
+	etcd.mu.Lock()
+	etcd.kvs["/ringpop/10.0.0.3:5000"] = "10.0.0.3:5000"
+	etcd.mu.Unlock()
+	etcd.compact <- struct{}{}
+	waitForHosts(t, p, []string{"10.0.0.1:5000", "10.0.0.3:5000"})
+
+	p.Stop()
+
+	etcd.mu.Lock()
+	defer etcd.mu.Unlock()
+	if !etcd.revoked || len(etcd.kvs) != 0 {
+		t.Fatalf("Node isn't deregistered: %v", etcd.kvs)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for prefix, expected := range map[string]string{
+		"/ringpop/": "/ringpop0",
+		"a\xff":     "b",
+		"\xff":      "\x00",
+	} {
+		if end := string(prefixEnd([]byte(prefix))); end != expected {
+			t.Fatalf("Unexpected end of prefix %q: %q, expected: %q", prefix, end, expected)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ozontech/http-ringpop/ring"
+	"github.com/uber-common/bark"
+	"gopkg.in/yaml.v2"
+)
+
+// FileHost is an entry of hosts file, it's either address string or object with address
+// and optional weight and labels of the host
+type FileHost struct {
+	Address string            `json:"address" yaml:"address"`
+	Weight  int               `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// fileHostObject has no custom unmarshalers of FileHost
+type fileHostObject FileHost
+
+func (h *FileHost) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*h = FileHost{}
+		return json.Unmarshal(data, &h.Address)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*fileHostObject)(h))
+}
+
+func (h *FileHost) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*h = FileHost{}
+	if err := unmarshal(&h.Address); err == nil {
+		return nil
+	}
+
+	return unmarshal((*fileHostObject)(h))
+}
+
+// FileProvider discovers hosts from JSON or YAML file (by .yaml or .yml extension).
+// File is reloaded when its content changes, invalid content is rejected and the last valid hosts are kept.
+// Weight and labels of this node are shared by ring, see Labels.
+// Compatible with github.com/uber/ringpop-go/discovery.DiscoveryProvider interface
+//
+// JSON file example:
+// ["127.0.0.1:3000", {"address": "127.0.0.1:3001", "weight": 2, "labels": {"zone": "b"}}]
+type FileProvider struct {
+	path     string
+	interval time.Duration
+	logger   bark.Logger
+
+	hosts *hostSet
+
+	mu      sync.RWMutex
+	entries map[string]FileHost
+	lastErr string
+
+	// content is owned by reloading goroutine
+	content []byte
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newFileProvider returns provider that reloads file every interval until Stop is called,
+// file is read only once when interval is 0
+func newFileProvider(path string, interval time.Duration, logger bark.Logger) *FileProvider {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &FileProvider{
+		path:     path,
+		interval: interval,
+		logger:   logger,
+		hosts:    newHostSet(),
+		entries:  make(map[string]FileHost),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	p.reload()
+	if interval <= 0 {
+		close(p.done)
+		return p
+	}
+	go p.run(ctx)
+
+	return p
+}
+
+// Hosts returns addresses of the last valid file
+func (p *FileProvider) Hosts() ([]string, error) {
+	if hosts, synced := p.hosts.get(); synced {
+		return hosts, nil
+	}
+
+	// The first load failed, file is read again to return the error or hosts of fixed file
+	entries, _, err := readHostsFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		hosts = append(hosts, entry.Address)
+	}
+
+	return dedupe(hosts), nil
+}
+
+// Labels returns labels of host including its weight, false is returned when host isn't in file
+func (p *FileProvider) Labels(address string) (map[string]string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	entry, ok := p.entries[address]
+	if !ok {
+		return nil, false
+	}
+
+	labels := make(map[string]string, len(entry.Labels)+1)
+	for name, value := range entry.Labels {
+		labels[name] = value
+	}
+	if entry.Weight > 0 {
+		labels[ring.LabelWeight] = strconv.Itoa(entry.Weight)
+	}
+
+	return labels, true
+}
+
+// Changed returns channel that receives value when hosts in file change
+func (p *FileProvider) Changed() <-chan struct{} {
+	return p.hosts.changed
+}
+
+// Stop stops reloading file
+func (p *FileProvider) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+func (p *FileProvider) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.reload()
+	}
+}
+
+// reload applies content of file when it's changed and valid
+func (p *FileProvider) reload() {
+	entries, content, err := readHostsFile(p.path)
+	if err != nil {
+		// The same error isn't logged on every reload
+		if err.Error() != p.lastError() {
+			p.logger.Errorf("Unable to load hosts file, the last valid hosts are kept: %v", err)
+			p.mu.Lock()
+			p.lastErr = err.Error()
+			p.mu.Unlock()
+		}
+		return
+	}
+
+	p.mu.Lock()
+	p.lastErr = ""
+	p.mu.Unlock()
+	if p.content != nil && bytes.Equal(content, p.content) {
+		return
+	}
+	p.content = content
+
+	hosts := make([]string, 0, len(entries))
+	byAddress := make(map[string]FileHost, len(entries))
+	for _, entry := range entries {
+		hosts = append(hosts, entry.Address)
+		byAddress[entry.Address] = entry
+	}
+
+	p.mu.Lock()
+	p.entries = byAddress
+	p.mu.Unlock()
+
+	if p.hosts.set(hosts) {
+		p.logger.Infof("Discovered endpoints in file %s: %s", p.path, strings.Join(hosts, ", "))
+	}
+}
+
+// lastError returns error of the last reload, it's empty when file was applied
+func (p *FileProvider) lastError() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.lastErr
+}
+
+// readHostsFile returns valid entries and content of file
+func readHostsFile(path string) ([]FileHost, []byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := parseHostsFile(path, content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entries, content, nil
+}
+
+// parseHostsFile parses and validates hosts, format is chosen by extension of file
+func parseHostsFile(path string, content []byte) ([]FileHost, error) {
+	entries := []FileHost{}
+
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, &entries)
+	default:
+		err = json.Unmarshal(content, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		if err := validateFileHost(entry); err != nil {
+			return nil, fmt.Errorf("%s: host #%d: %v", path, i+1, err)
+		}
+		if seen[entry.Address] {
+			return nil, fmt.Errorf("%s: host #%d: duplicate address %s", path, i+1, entry.Address)
+		}
+		seen[entry.Address] = true
+	}
+
+	return entries, nil
+}
+
+func validateFileHost(entry FileHost) error {
+	host, port, err := net.SplitHostPort(entry.Address)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("no host in address %s", entry.Address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port in address %s", entry.Address)
+	}
+
+	if entry.Weight < 0 || entry.Weight > ring.MaxWeight {
+		return fmt.Errorf("weight %d isn't from 0 to %d", entry.Weight, ring.MaxWeight)
+	}
+	for name := range entry.Labels {
+		if name == "" {
+			return errors.New("empty label name")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+)
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.json")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to write hosts file: %v", err)
+		}
+	}
+	waitForHosts := func(p *FileProvider, expected []string) {
+		for i := 0; ; i++ {
+			hosts, err := p.Hosts()
+			if err == nil && reflect.DeepEqual(hosts, expected) {
+				return
+			}
+			if i == 100 {
+				t.Fatalf("Unexpected hosts: %v (%v), expected: %v", hosts, err, expected)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	write(`["127.0.0.1:5001", {"address": "127.0.0.1:5000", "weight": 2, "labels": {"zone": "a"}}]`)
+
+	p := newFileProvider(path, 10*time.Millisecond, bark.NewLoggerFromLogrus(logrus.New()))
+	defer p.Stop()
+
+	waitForHosts(p, []string{"127.0.0.1:5000", "127.0.0.1:5001"})
+	labels, ok := p.Labels("127.0.0.1:5000")
+	if !ok || !reflect.DeepEqual(labels, map[string]string{"weight": "2", "zone": "a"}) {
+		t.Fatalf("Unexpected labels: %v", labels)
+	}
+
+	// Invalid file is rejected, the last valid hosts are kept
+	write(`["127.0.0.1:5001", "127.0.0.1"]`)
+	for i := 0; p.lastError() == ""; i++ {
+		if i == 100 {
+			t.Fatal("Invalid file isn't reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hosts, err := p.Hosts(); err != nil || !reflect.DeepEqual(hosts, []string{"127.0.0.1:5000", "127.0.0.1:5001"}) {
+		t.Fatalf("Unexpected hosts after invalid file: %v, %v", hosts, err)
+	}
+
+	select {
+	case <-p.Changed():
+	default:
+	}
+
+	write(`["127.0.0.1:5001", "127.0.0.1:5002"]`)
+	waitForHosts(p, []string{"127.0.0.1:5001", "127.0.0.1:5002"})
+	select {
+	case <-p.Changed():
+	case <-time.After(time.Second):
+		t.Fatal("Change of hosts isn't notified")
+	}
+	if _, ok := p.Labels("127.0.0.1:5000"); ok {
+		t.Fatal("Labels of removed host are kept")
+	}
+}
+
+func TestParseHostsFile(t *testing.T) {
+	yamlHosts := `
+- 127.0.0.1:5000
+- address: 127.0.0.1:5001
+  weight: 3
+  labels:
+    zone: b
+`
+	entries, err := parseHostsFile("hosts.yaml", []byte(yamlHosts))
+	if err != nil {
+		t.Fatalf("Unable to parse YAML: %v", err)
+	}
+	expected := []FileHost{
+		{Address: "127.0.0.1:5000"},
+		{Address: "127.0.0.1:5001", Weight: 3, Labels: map[string]string{"zone": "b"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Unexpected entries: %+v, expected: %+v", entries, expected)
+	}
+
+	for _, invalid := range []string{
+		`["127.0.0.1"]`,
+		`["127.0.0.1:0"]`,
+		`[":5000"]`,
+		`["127.0.0.1:5000", "127.0.0.1:5000"]`,
+		`[{"address": "127.0.0.1:5000", "weight": -1}]`,
+		`[{"address": "127.0.0.1:5000", "weight": 101}]`,
+		`[{"adress": "127.0.0.1:5000"}]`,
+		`{"hosts": []}`,
+	} {
+		if _, err := parseHostsFile("hosts.json", []byte(invalid)); err == nil {
+			t.Fatalf("Error is expected for %s", invalid)
+		}
+	}
+}
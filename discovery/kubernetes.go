@@ -0,0 +1,348 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-common/bark"
+)
+
+const (
+	// kubernetesWatchTimeout makes API server close watch stream, it's reopened from the last seen version
+	kubernetesWatchTimeout = 5 * time.Minute
+
+	kubernetesMinBackoff = time.Second
+	kubernetesMaxBackoff = 30 * time.Second
+
+	// kubernetesRequestTimeout limits all requests to Kubernetes API except watch
+	kubernetesRequestTimeout = 5 * time.Second
+)
+
+// KubernetesConfig describes which EndpointSlices contain ring members
+type KubernetesConfig struct {
+	// Kubeconfig is a path to kubeconfig file, in-cluster config of service account is used when it's empty
+	Kubeconfig string
+	// Namespace of EndpointSlices, namespace of pod (or kubeconfig context) is used when it's empty
+	Namespace string
+	// LabelSelector filters EndpointSlices, e.g. kubernetes.io/service-name=ringpop
+	LabelSelector string
+	// PortName is a name of ringpop port in EndpointSlices, it could be empty when slices have the only port
+	PortName string
+	// NotReady makes endpoints of pods that aren't ready discovered too
+	NotReady bool
+}
+
+// KubernetesProvider discovers hosts from EndpointSlices of Kubernetes API.
+// Slices are watched in background, so hosts are always up to date.
+// Compatible with github.com/uber/ringpop-go/discovery.DiscoveryProvider interface
+type KubernetesProvider struct {
+	api    *kubernetesClient
+	config KubernetesConfig
+	logger bark.Logger
+
+	// slices maps name of slice to its hosts, it's owned by watching goroutine
+	slices map[string][]string
+	hosts  *hostSet
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// endpointSlice is a part of discovery.k8s.io/v1 EndpointSlice used by provider
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name *string `json:"name"`
+		Port *int    `json:"port"`
+	} `json:"ports"`
+}
+
+type endpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []endpointSlice `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// watchStatus is an object of ERROR event
+type watchStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// errExpired is returned when watched version is too old, slices are listed again
+var errExpired = errors.New("resource version expired")
+
+// newKubernetesProvider returns provider that watches EndpointSlices until Stop is called
+func newKubernetesProvider(cfg KubernetesConfig, logger bark.Logger) (*KubernetesProvider, error) {
+	var api *kubernetesClient
+	var namespace string
+	var err error
+	if cfg.Kubeconfig != "" {
+		api, namespace, err = newKubeconfigClient(cfg.Kubeconfig)
+	} else {
+		api, namespace, err = newInClusterClient()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Namespace == "" {
+		cfg.Namespace = namespace
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &KubernetesProvider{
+		api:    api,
+		config: cfg,
+		logger: logger,
+		slices: make(map[string][]string),
+		hosts:  newHostSet(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx)
+
+	return p, nil
+}
+
+// Hosts returns ringpop addresses of discovered endpoints
+func (p *KubernetesProvider) Hosts() ([]string, error) {
+	if hosts, synced := p.hosts.get(); synced {
+		return hosts, nil
+	}
+
+	// Watch hasn't listed slices yet
+	list, err := p.list(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, slice := range list.Items {
+		hosts = append(hosts, p.sliceHosts(slice)...)
+	}
+
+	return dedupe(hosts), nil
+}
+
+// Changed returns channel that receives value when discovered hosts change
+func (p *KubernetesProvider) Changed() <-chan struct{} {
+	return p.hosts.changed
+}
+
+// Stop stops watching EndpointSlices
+func (p *KubernetesProvider) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+func (p *KubernetesProvider) run(ctx context.Context) {
+	defer close(p.done)
+
+	backoff := kubernetesMinBackoff
+	for {
+		err := p.listAndWatch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == errExpired {
+			backoff = kubernetesMinBackoff
+			continue
+		}
+		p.logger.Warnf("Unable to watch Kubernetes EndpointSlices, retrying in %v: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > kubernetesMaxBackoff {
+			backoff = kubernetesMaxBackoff
+		}
+	}
+}
+
+// listAndWatch lists slices and keeps watching their changes until error
+func (p *KubernetesProvider) listAndWatch(ctx context.Context) error {
+	list, err := p.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.slices = make(map[string][]string, len(list.Items))
+	for _, slice := range list.Items {
+		p.slices[slice.Metadata.Name] = p.sliceHosts(slice)
+	}
+	p.update()
+
+	version := list.Metadata.ResourceVersion
+	for {
+		started := time.Now()
+		next, err := p.watch(ctx, version)
+		if err != nil {
+			return err
+		}
+
+		// Stream closed right away without any event (e.g. by proxy) is retried with backoff, not in a loop
+		if next == version && time.Since(started) < kubernetesMinBackoff {
+			return errors.New("watch stream is closed right after it's opened")
+		}
+		version = next
+	}
+}
+
+// watch applies changes of slices after given version until stream is closed, it returns the last seen version
+func (p *KubernetesProvider) watch(ctx context.Context, version string) (string, error) {
+	query := p.query()
+	query.Set("watch", "true")
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("resourceVersion", version)
+	query.Set("timeoutSeconds", strconv.Itoa(int(kubernetesWatchTimeout.Seconds())))
+
+	resp, err := p.api.get(ctx, p.path(), query)
+	if err != nil {
+		return version, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return version, ctx.Err()
+			}
+			// Stream is closed by API server after timeout
+			if err == io.EOF {
+				return version, nil
+			}
+			return version, err
+		}
+
+		if event.Type == "ERROR" {
+			var status watchStatus
+			json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return version, errExpired
+			}
+			return version, fmt.Errorf("watch error %d: %s", status.Code, status.Message)
+		}
+
+		var slice endpointSlice
+		if err := json.Unmarshal(event.Object, &slice); err != nil {
+			return version, err
+		}
+		version = slice.Metadata.ResourceVersion
+
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			p.slices[slice.Metadata.Name] = p.sliceHosts(slice)
+			p.update()
+		case "DELETED":
+			delete(p.slices, slice.Metadata.Name)
+			p.update()
+		}
+	}
+}
+
+// update shares hosts of all slices
+func (p *KubernetesProvider) update() {
+	var hosts []string
+	for _, sliceHosts := range p.slices {
+		hosts = append(hosts, sliceHosts...)
+	}
+
+	hosts = dedupe(hosts)
+	if p.hosts.set(hosts) {
+		p.logger.Infof("Discovered endpoints in Kubernetes: %s", strings.Join(hosts, ", "))
+	}
+}
+
+func (p *KubernetesProvider) list(ctx context.Context) (*endpointSliceList, error) {
+	ctx, cancel := context.WithTimeout(ctx, kubernetesRequestTimeout)
+	defer cancel()
+
+	resp, err := p.api.get(ctx, p.path(), p.query())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	list := &endpointSliceList{}
+	if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (p *KubernetesProvider) path() string {
+	return "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(p.config.Namespace) + "/endpointslices"
+}
+
+func (p *KubernetesProvider) query() url.Values {
+	query := url.Values{}
+	if p.config.LabelSelector != "" {
+		query.Set("labelSelector", p.config.LabelSelector)
+	}
+
+	return query
+}
+
+// sliceHosts returns addresses of slice endpoints with ringpop port
+func (p *KubernetesProvider) sliceHosts(slice endpointSlice) []string {
+	port := 0
+	for _, sp := range slice.Ports {
+		name := ""
+		if sp.Name != nil {
+			name = *sp.Name
+		}
+		if sp.Port != nil && (name == p.config.PortName || p.config.PortName == "" && len(slice.Ports) == 1) {
+			port = *sp.Port
+			break
+		}
+	}
+	if port == 0 {
+		return nil
+	}
+
+	var hosts []string
+	for _, endpoint := range slice.Endpoints {
+		// Unknown readiness is treated as ready
+		ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+		if len(endpoint.Addresses) == 0 || !ready && !p.config.NotReady {
+			continue
+		}
+
+		// All addresses of endpoint are fungible, the first one is used
+		hosts = append(hosts, net.JoinHostPort(endpoint.Addresses[0], strconv.Itoa(port)))
+	}
+
+	return hosts
+}
@@ -0,0 +1,260 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// kubernetesServiceAccountDir contains credentials of pod's service account
+const kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubernetesClient makes authenticated requests to Kubernetes API.
+// It's used instead of client-go: the provider needs a single list and watch request,
+// while client-go would pull dozens of modules and newer Go than the module requires.
+// Only token, token file and client certificate credentials are supported.
+type kubernetesClient struct {
+	server string
+	client *http.Client
+
+	token string
+	// tokenFile is read on every request, bound service account tokens are rotated
+	tokenFile string
+}
+
+// kubeconfig is a part of kubeconfig file used by client
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			// Credential plugins and basic auth aren't supported, they are checked to fail clearly
+			Exec         interface{} `yaml:"exec"`
+			AuthProvider interface{} `yaml:"auth-provider"`
+			Username     string      `yaml:"username"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// newInClusterClient returns client authenticated as pod's service account and namespace of pod
+func newInClusterClient() (*kubernetesClient, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", errors.New("not running in Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+
+	ca, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, "", err
+	}
+	tlsConfig, err := newTLSConfig(ca)
+	if err != nil {
+		return nil, "", err
+	}
+
+	namespace, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &kubernetesClient{
+		server:    "https://" + net.JoinHostPort(host, port),
+		client:    newKubernetesHTTPClient(tlsConfig),
+		tokenFile: filepath.Join(kubernetesServiceAccountDir, "token"),
+	}, strings.TrimSpace(string(namespace)), nil
+}
+
+// newKubeconfigClient returns client of current context of kubeconfig file and its namespace
+func newKubeconfigClient(path string) (*kubernetesClient, string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, "", fmt.Errorf("invalid kubeconfig %s: %v", path, err)
+	}
+
+	// Relative paths of kubeconfig are relative to its directory
+	dir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+
+	namespace := "default"
+	client := &kubernetesClient{}
+	tlsConfig := &tls.Config{}
+	for _, c := range config.Contexts {
+		if c.Name != config.CurrentContext {
+			continue
+		}
+		if c.Context.Namespace != "" {
+			namespace = c.Context.Namespace
+		}
+
+		for _, cluster := range config.Clusters {
+			if cluster.Name != c.Context.Cluster {
+				continue
+			}
+
+			client.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+			ca, err := fileOrData(resolve(cluster.Cluster.CertificateAuthority), cluster.Cluster.CertificateAuthorityData)
+			if err != nil {
+				return nil, "", err
+			}
+			if tlsConfig, err = newTLSConfig(ca); err != nil {
+				return nil, "", err
+			}
+			tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+		}
+
+		for _, user := range config.Users {
+			if user.Name != c.Context.User {
+				continue
+			}
+
+			switch {
+			case user.User.Exec != nil:
+				return nil, "", fmt.Errorf("user %q of kubeconfig %s uses exec credential plugin, it isn't supported: use token, tokenFile or client certificate", user.Name, path)
+			case user.User.AuthProvider != nil:
+				return nil, "", fmt.Errorf("user %q of kubeconfig %s uses auth-provider plugin, it isn't supported: use token, tokenFile or client certificate", user.Name, path)
+			case user.User.Username != "":
+				return nil, "", fmt.Errorf("user %q of kubeconfig %s uses basic auth, it isn't supported: use token, tokenFile or client certificate", user.Name, path)
+			}
+
+			client.token = user.User.Token
+			client.tokenFile = resolve(user.User.TokenFile)
+
+			cert, err := fileOrData(resolve(user.User.ClientCertificate), user.User.ClientCertificateData)
+			if err != nil {
+				return nil, "", err
+			}
+			key, err := fileOrData(resolve(user.User.ClientKey), user.User.ClientKeyData)
+			if err != nil {
+				return nil, "", err
+			}
+			if cert != nil {
+				pair, err := tls.X509KeyPair(cert, key)
+				if err != nil {
+					return nil, "", err
+				}
+				tlsConfig.Certificates = []tls.Certificate{pair}
+			}
+		}
+	}
+
+	if client.server == "" {
+		return nil, "", fmt.Errorf("cluster of current context %q isn't found in kubeconfig %s", config.CurrentContext, path)
+	}
+	client.client = newKubernetesHTTPClient(tlsConfig)
+
+	return client, namespace, nil
+}
+
+// fileOrData returns content of file or decoded base64 data
+func fileOrData(file, data string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return ioutil.ReadFile(file)
+	}
+
+	return nil, nil
+}
+
+// newTLSConfig returns TLS config trusting given CA, system roots are used when CA is empty
+func newTLSConfig(ca []byte) (*tls.Config, error) {
+	config := &tls.Config{}
+	if len(ca) == 0 {
+		return config, nil
+	}
+
+	config.RootCAs = x509.NewCertPool()
+	if !config.RootCAs.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid certificate of Kubernetes CA")
+	}
+
+	return config, nil
+}
+
+func newKubernetesHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	// Watch requests are long, they are limited by timeoutSeconds parameter and context
+	return &http.Client{Transport: transport}
+}
+
+// get makes GET request and returns response with 200 status
+func (c *kubernetesClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token := c.token
+	if c.tokenFile != "" {
+		data, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("Kubernetes API responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
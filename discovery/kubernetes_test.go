@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+)
+
+const testEndpointSlice = `{
+	"metadata": {"name": "%s", "resourceVersion": "%s"},
+	"endpoints": [
+		{"addresses": ["%s"], "conditions": {"ready": true}},
+		{"addresses": ["10.0.0.99"], "conditions": {"ready": false}}
+	],
+	"ports": [{"name": "http", "port": 3000}, {"name": "ringpop", "port": 5000}]
+}`
+
+// newFakeKubernetes returns stand-in of Kubernetes API that lists one slice and adds another one by watch
+func newFakeKubernetes(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/ringpop/endpointslices" ||
+			r.URL.Query().Get("labelSelector") != "kubernetes.io/service-name=ringpop" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [`+testEndpointSlice+`]}`, "first", "1", "10.0.0.1")
+			return
+		}
+
+		if r.URL.Query().Get("resourceVersion") == "1" {
+			fmt.Fprintf(w, `{"type": "ADDED", "object": `+testEndpointSlice+"}\n", "second", "2", "10.0.0.2")
+			fmt.Fprint(w, `{"type": "BOOKMARK", "object": {"metadata": {"resourceVersion": "3"}}}`+"\n")
+			return
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// writeKubeconfig returns path of kubeconfig file with given server and user
+func writeKubeconfig(t *testing.T, server, user string) string {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := ioutil.WriteFile(kubeconfig, []byte(`
+current-context: test
+contexts:
+- name: test
+  context: {cluster: test, user: test, namespace: ringpop}
+clusters:
+- name: test
+  cluster: {server: "`+server+`"}
+users:
+- name: test
+  user: `+user+`
+`), 0o600); err != nil {
+		t.Fatalf("Unable to write kubeconfig: %v", err)
+	}
+
+	return kubeconfig
+}
+
+func TestKubernetesProvider(t *testing.T) {
+	srv := newFakeKubernetes(t)
+	kubeconfig := writeKubeconfig(t, srv.URL, "{token: secret}")
+
+	p, err := newKubernetesProvider(KubernetesConfig{
+		Kubeconfig:    kubeconfig,
+		LabelSelector: "kubernetes.io/service-name=ringpop",
+		PortName:      "ringpop",
+	}, bark.NewLoggerFromLogrus(logrus.New()))
+	if err != nil {
+		t.Fatalf("Unable to create provider: %v", err)
+	}
+	defer p.Stop()
+
+	expected := []string{"10.0.0.1:5000", "10.0.0.2:5000"}
+	for i := 0; ; i++ {
+		hosts, err := p.Hosts()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reflect.DeepEqual(hosts, expected) {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("Unexpected hosts: %v, expected: %v", hosts, expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-p.Changed():
+	default:
+		t.Fatal("Change of hosts isn't notified")
+	}
+}
+
+func TestKubernetesWatchClosedImmediately(t *testing.T) {
+	var watches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			atomic.AddInt32(&watches, 1)
+			return
+		}
+		fmt.Fprint(w, `{"metadata": {"resourceVersion": "1"}, "items": []}`)
+	}))
+	defer srv.Close()
+
+	p, err := newKubernetesProvider(KubernetesConfig{
+		Kubeconfig: writeKubeconfig(t, srv.URL, "{token: secret}"),
+	}, bark.NewLoggerFromLogrus(logrus.New()))
+	if err != nil {
+		t.Fatalf("Unable to create provider: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	p.Stop()
+
+	// Watch is retried after backoff, not in a loop
+	if count := atomic.LoadInt32(&watches); count != 1 {
+		t.Fatalf("Unexpected number of watches: %d", count)
+	}
+}
+
+func TestKubeconfigUnsupportedUser(t *testing.T) {
+	for _, user := range []string{
+		"{exec: {command: aws, apiVersion: client.authentication.k8s.io/v1beta1}}",
+		"{auth-provider: {name: gcp}}",
+		"{username: admin, password: secret}",
+	} {
+		if _, _, err := newKubeconfigClient(writeKubeconfig(t, "https://127.0.0.1:6443", user)); err == nil {
+			t.Fatalf("Error is expected for user %s", user)
+		}
+	}
+}
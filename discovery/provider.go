@@ -2,17 +2,39 @@ package discovery
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/uber-common/bark"
 	"github.com/uber/ringpop-go/discovery"
 )
 
+// Watcher is implemented by providers which learn about changes of hosts,
+// new hosts are joined right after the change instead of the next refresh
+type Watcher interface {
+	Changed() <-chan struct{}
+}
+
+// Labeler is implemented by providers which discover labels of hosts, e.g. weight in hosts file,
+// labels of this node are shared with the ring
+type Labeler interface {
+	Labels(address string) (map[string]string, bool)
+}
+
 // providerBuilder is a simple builder for discovery provider
 type providerBuilder struct {
-	jsonFilePath string
+	jsonFilePath       string
+	fileReloadInterval time.Duration
 
 	dnsHost     string
 	dnsHostPort int
+	dnsSRVName  string
+
+	kubernetes *KubernetesConfig
+	consul     *ConsulConfig
+	etcd       *EtcdConfig
+
+	mode string
 
 	logger bark.Logger
 }
@@ -29,20 +51,113 @@ func (b *providerBuilder) WithJSONFileDiscovery(jsonFilePath string) *providerBu
 	return b
 }
 
+// WithFileReloadInterval makes hosts file reloaded every interval, it's read only once by default
+func (b *providerBuilder) WithFileReloadInterval(interval time.Duration) *providerBuilder {
+	b.fileReloadInterval = interval
+	return b
+}
+
 func (b *providerBuilder) WithDNSDiscovery(dnsHost string, dnsHostPort int) *providerBuilder {
 	b.dnsHost = dnsHost
 	b.dnsHostPort = dnsHostPort
 	return b
 }
 
+func (b *providerBuilder) WithDNSSRVDiscovery(name string) *providerBuilder {
+	b.dnsSRVName = name
+	return b
+}
+
+func (b *providerBuilder) WithKubernetesDiscovery(cfg KubernetesConfig) *providerBuilder {
+	b.kubernetes = &cfg
+	return b
+}
+
+func (b *providerBuilder) WithConsulDiscovery(cfg ConsulConfig) *providerBuilder {
+	b.consul = &cfg
+	return b
+}
+
+func (b *providerBuilder) WithEtcdDiscovery(cfg EtcdConfig) *providerBuilder {
+	b.etcd = &cfg
+	return b
+}
+
+// WithMode sets how several providers are combined: ModeUnion or ModeFallback
+func (b *providerBuilder) WithMode(mode string) *providerBuilder {
+	b.mode = mode
+	return b
+}
+
+// Build returns configured provider. Several providers are combined according to mode,
+// they are queried in order: DNS, DNS SRV, Kubernetes, Consul, etcd, JSON file.
+// So static file is the last resort in fallback mode.
 func (b *providerBuilder) Build() (discovery.DiscoverProvider, error) {
-	if b.jsonFilePath != "" {
-		return newJSONFileDiscovery(b.jsonFilePath), nil
+	switch b.mode {
+	case "", ModeUnion, ModeFallback:
+	default:
+		return nil, fmt.Errorf("Unknown mode of combining discovery providers: %s", b.mode)
+	}
+
+	if (b.dnsHost == "") != (b.dnsHostPort == 0) {
+		return nil, errors.New("Both host and port are required for DNS discovery")
+	}
+
+	providers, err := b.providers()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(providers) == 0:
+		return nil, errors.New("Not enough arguments for building discovery provider")
+	case len(providers) == 1:
+		return providers[0], nil
+	case b.mode == "":
+		stopProviders(providers)
+		return nil, fmt.Errorf("%d discovery providers are configured, mode of combining them is required", len(providers))
+	}
+
+	return newCompositeProvider(providers, b.mode, b.logger), nil
+}
+
+// providers returns all configured providers, created providers are stopped on error
+func (b *providerBuilder) providers() ([]discovery.DiscoverProvider, error) {
+	var providers []discovery.DiscoverProvider
+
+	if b.dnsHost != "" {
+		providers = append(providers, newDNSProvider(b.dnsHost, b.dnsHostPort, b.logger))
+	}
+
+	if b.dnsSRVName != "" {
+		providers = append(providers, newDNSSRVProvider(b.dnsSRVName, b.logger))
+	}
+
+	if b.kubernetes != nil {
+		provider, err := newKubernetesProvider(*b.kubernetes, b.logger)
+		if err != nil {
+			stopProviders(providers)
+			return nil, err
+		}
+		providers = append(providers, provider)
 	}
 
-	if b.dnsHost != "" && b.dnsHostPort != 0 {
-		return newDNSProvider(b.dnsHost, b.dnsHostPort, b.logger), nil
+	if b.consul != nil {
+		providers = append(providers, newConsulProvider(*b.consul, b.logger))
+	}
+
+	if b.etcd != nil {
+		provider, err := newEtcdProvider(*b.etcd, b.logger)
+		if err != nil {
+			stopProviders(providers)
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+
+	if b.jsonFilePath != "" {
+		providers = append(providers, newFileProvider(b.jsonFilePath, b.fileReloadInterval, b.logger))
 	}
 
-	return nil, errors.New("Not enough arguments for building discovery provider")
+	return providers, nil
 }
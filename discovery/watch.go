@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// hostSet keeps hosts discovered by watching provider and notifies about their changes
+type hostSet struct {
+	mu     sync.RWMutex
+	hosts  []string
+	synced bool
+
+	changed chan struct{}
+}
+
+func newHostSet() *hostSet {
+	return &hostSet{
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// get returns hosts and whether they have been set at least once
+func (s *hostSet) get() ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.hosts...), s.synced
+}
+
+// set replaces hosts, it returns true and notifies when hosts are changed
+func (s *hostSet) set(hosts []string) bool {
+	hosts = dedupe(hosts)
+
+	s.mu.Lock()
+	changed := strings.Join(s.hosts, ",") != strings.Join(hosts, ",")
+	s.hosts = hosts
+	s.synced = true
+	s.mu.Unlock()
+
+	if changed {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+
+	return changed
+}
+
+// dedupe returns sorted unique hosts
+func dedupe(hosts []string) []string {
+	sort.Strings(hosts)
+
+	unique := hosts[:0]
+	for i, host := range hosts {
+		if i == 0 || host != hosts[i-1] {
+			unique = append(unique, host)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ozontech/http-ringpop/ring"
+)
+
+// maxExplainSuccessors limits number of successors returned by Explain
+const maxExplainSuccessors = 100
+
+// ExplainRequest is a sample request to explain
+type ExplainRequest struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	RemoteAddr string            `json:"remote_addr,omitempty"`
+	// Successors is a number of key owner successors to show, 2 by default
+	Successors *int `json:"successors,omitempty"`
+}
+
+// Explanation describes how request would be routed
+type Explanation struct {
+	Key       string `json:"key"`
+	KeySource string `json:"key_source"`
+	Route     string `json:"route,omitempty"`
+	// ReplicatedRead is true if request could be served by successors of key owner
+	ReplicatedRead bool `json:"replicated_read"`
+	HotKey         bool `json:"hot_key"`
+
+	// Owner and Successors are nodes of key in the ring, health of their backends isn't taken into account
+	Owner      string   `json:"owner"`
+	Successors []string `json:"successors"`
+	// Destination is a node request would be sent to, it differs from owner when owner's backend
+	// is unhealthy or owner is overloaded. Requests of hot keys are spread among owner and successors.
+	Destination string `json:"destination"`
+	Node        string `json:"node"`
+	Local       bool   `json:"local"`
+}
+
+// Explain shows how sample request would be routed by this node.
+// Request is described by JSON body (see ExplainRequest) or by query parameters:
+// /debug/explain?method=GET&path=/api&header=X-Forwarded-For:1.2.3.4&successors=2
+func (srv *HTTPServer) Explain(w http.ResponseWriter, r *http.Request) {
+	sample, err := parseExplainRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	successors := 2
+	if sample.Successors != nil {
+		successors = *sample.Successors
+	}
+	if successors < 0 || successors > maxExplainSuccessors {
+		http.Error(w, "successors must be a number from 0 to "+strconv.Itoa(maxExplainSuccessors), http.StatusBadRequest)
+		return
+	}
+
+	req, err := http.NewRequest(sample.Method, sample.Path, nil)
+	if err != nil {
+		http.Error(w, "invalid sample request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name, value := range sample.Headers {
+		req.Header.Set(name, value)
+	}
+	req.RemoteAddr = sample.RemoteAddr
+
+	e := &Explanation{}
+	e.Key, e.KeySource = ring.ExtractKey(req)
+	if rule := srv.routes.Match(req); rule != nil {
+		e.Route = rule.Name
+		e.ReplicatedRead = rule.IsReplicatedRead(req)
+	}
+	e.HotKey = srv.hotKeys != nil && srv.hotKeys.IsHot(e.Key)
+
+	nodes, err := ring.LookupNodes(srv.ringpop, e.Key, successors+1, srv.resolveOptions...)
+	if err != nil {
+		http.Error(w, "Can't resolve key owner: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	e.Owner, e.Successors = nodes[0], nodes[1:]
+
+	if e.Destination, err = ring.ResolveDestinationNode(srv.ringpop, e.Key, srv.resolveOptions...); err != nil {
+		http.Error(w, "Can't resolve dst node: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if e.Node, err = srv.ringpop.WhoAmI(); err != nil {
+		http.Error(w, "Can't resolve who am I: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	e.Local = e.Node == e.Destination
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+func parseExplainRequest(r *http.Request) (*ExplainRequest, error) {
+	sample := &ExplainRequest{}
+
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(sample); err != nil {
+			return nil, err
+		}
+	} else {
+		query := r.URL.Query()
+		sample.Method = query.Get("method")
+		sample.Path = query.Get("path")
+		sample.RemoteAddr = query.Get("remote_addr")
+		if value := query.Get("successors"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			sample.Successors = &n
+		}
+
+		sample.Headers = make(map[string]string)
+		for _, header := range query["header"] {
+			i := strings.IndexByte(header, ':')
+			if i < 0 {
+				return nil, fmt.Errorf("header must be in Name:Value format: %s", header)
+			}
+			sample.Headers[strings.TrimSpace(header[:i])] = strings.TrimSpace(header[i+1:])
+		}
+	}
+
+	if sample.Method == "" {
+		sample.Method = http.MethodGet
+	}
+	if sample.Path == "" {
+		sample.Path = "/"
+	}
+
+	return sample, nil
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ozontech/http-ringpop/pkg/route"
+	"github.com/ozontech/http-ringpop/ring"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go/discovery/statichosts"
+)
+
+func TestExplain(t *testing.T) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+
+	ch, err := ring.NewChannel()
+	if err != nil {
+		t.Fatalf("Unable to create channel: %v", err)
+	}
+	defer ch.Close()
+	if err := ch.ListenAndServe("127.0.0.1:0"); err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	address := ch.PeerInfo().HostPort
+
+	host, port := splitHostPort(t, address)
+	rp, err := ring.NewRingpop(ch, host, port, logger)
+	if err != nil {
+		t.Fatalf("Unable to create ringpop: %v", err)
+	}
+	defer rp.Destroy()
+	if err := ring.BootstrapRingpop(rp, statichosts.New(address)); err != nil {
+		t.Fatalf("Unable to bootstrap ringpop: %v", err)
+	}
+
+	srv := NewServer(rp, nil, nil, logger).WithRoutes(&route.Table{Rules: []*route.Rule{
+		{Name: "api", PathPrefix: "/api/", ReadReplicas: true},
+	}})
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/debug/explain", strings.NewReader(
+			`{"method": "GET", "path": "/api/users", "headers": {"X-Forwarded-For": "10.0.0.1, 93.184.216.34"}}`,
+		)),
+		httptest.NewRequest(http.MethodGet, "/debug/explain?path=/api/users&header=X-Forwarded-For:93.184.216.34", nil),
+	} {
+		w := httptest.NewRecorder()
+		srv.Explain(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status: %d, body: %s", w.Code, w.Body.String())
+		}
+
+		var e Explanation
+		if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+			t.Fatalf("Unable to decode explanation: %v", err)
+		}
+
+		expected := Explanation{
+			Key:            "93.184.216.34",
+			KeySource:      ring.KeySourceXForwardedFor,
+			Route:          "api",
+			ReplicatedRead: true,
+			Owner:          address,
+			Successors:     []string{},
+			Destination:    address,
+			Node:           address,
+			Local:          true,
+		}
+		if got, want := toJSON(t, e), toJSON(t, expected); got != want {
+			t.Fatalf("Unexpected explanation: %s, expected: %s", got, want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.Explain(w, httptest.NewRequest(http.MethodGet, "/debug/explain?header=invalid", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Unexpected status of invalid request: %d", w.Code)
+	}
+}
+
+func splitHostPort(t *testing.T, address string) (string, string) {
+	i := strings.LastIndexByte(address, ':')
+	if i < 0 {
+		t.Fatalf("Invalid address: %s", address)
+	}
+
+	return address[:i], address[i+1:]
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Unable to encode %v: %v", v, err)
+	}
+
+	return string(data)
+}
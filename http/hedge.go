@@ -0,0 +1,152 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/hedge"
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+	"github.com/ozontech/http-ringpop/ring"
+)
+
+var (
+	metricHedgedRequestsTotal    = metrics.MustRegisterCounter("hedged_requests_total", "Total number of hedged requests sent to successors of key owner")
+	metricHedgedRequestsWonTotal = metrics.MustRegisterCounter("hedged_requests_won_total", "Total number of hedged requests answered before the original ones")
+)
+
+// WithHedging enables hedged requests: if destination node hasn't answered in time,
+// the same request is sent to another replica and the first response wins.
+// It works for routes with read replicas only. Hedged and original requests are sent directly
+// to their nodes, so unlike other forwarded requests they aren't rerouted when ring changes.
+func (srv *HTTPServer) WithHedging(h *hedge.Hedger) *HTTPServer {
+	srv.hedger = h
+	return srv
+}
+
+// forwardResult is a response of node to forwarded request
+type forwardResult struct {
+	node     string
+	response []byte
+	err      error
+	hedged   bool
+}
+
+// forwardHedged forwards request to dstNode and, if it's slow, to another replica of key.
+// The slower request is canceled.
+func (srv *HTTPServer) forwardHedged(ctx context.Context, address, dstNode, key string, request []byte) forwardResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered, so the loser doesn't block after the winner is returned
+	results := make(chan forwardResult, 2)
+	send := func(node string, hedged bool) {
+		response, err := srv.forward(ctx, address, node, key, request)
+		results <- forwardResult{node: node, response: response, err: err, hedged: hedged}
+	}
+
+	start := time.Now()
+	go send(dstNode, false)
+	pending := 1
+
+	// Latency of original request is observed even if hedged one wins, otherwise delay
+	// would be calculated from fast responses only. Canceled request took at least elapsed time.
+	originalDone := false
+	defer func() {
+		if !originalDone {
+			srv.hedger.Observe(time.Since(start))
+		}
+	}()
+
+	timer := time.NewTimer(srv.hedger.Delay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			node := srv.hedgeNode(key, dstNode)
+			if node == "" || !srv.hedger.Allow() {
+				continue
+			}
+
+			srv.logger.Infof("Node %s is slow, sending hedged request to %s", dstNode, node)
+			metricHedgedRequestsTotal.Inc()
+
+			go send(node, true)
+			pending++
+
+		case result := <-results:
+			pending--
+
+			if !result.hedged {
+				originalDone = true
+				if result.err == nil {
+					srv.hedger.Observe(time.Since(start))
+				}
+			}
+
+			if result.err == nil {
+				if result.hedged {
+					metricHedgedRequestsWonTotal.Inc()
+				}
+				return result
+			}
+
+			// Other request still has a chance
+			if pending == 0 {
+				return result
+			}
+		}
+	}
+}
+
+// hedgeNode returns replica of key other than dstNode or empty string if there is no one
+func (srv *HTTPServer) hedgeNode(key, dstNode string) string {
+	nodes, err := ring.ResolveDestinationNodes(srv.ringpop, key, 2, srv.resolveOptions...)
+	if err != nil {
+		srv.logger.Errorf("Can't resolve replicas of key for hedged request: %v", err)
+		return ""
+	}
+
+	for _, node := range nodes {
+		if node != dstNode {
+			return node
+		}
+	}
+
+	return ""
+}
+
+// forward sends request to node and returns raw response, request to this node is served by local backend
+func (srv *HTTPServer) forward(ctx context.Context, address, node, key string, request []byte) ([]byte, error) {
+	if node == address {
+		return srv.serveLocally(ctx, address, request)
+	}
+
+	if f, ok := srv.requestForwarder.(ring.ContextForwarder); ok {
+		return f.ForwardContext(ctx, node, key, request)
+	}
+
+	return srv.requestForwarder.Forward(node, key, request)
+}
+
+// serveLocally passes raw request to local backend and returns raw response
+func (srv *HTTPServer) serveLocally(ctx context.Context, address string, request []byte) ([]byte, error) {
+	r, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request)))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set(headerProxy, address)
+
+	w := ring.NewResponseWriter()
+	srv.backend.ServeHTTP(w, r.WithContext(ctx))
+
+	response := &bytes.Buffer{}
+	if err := w.Response().Write(response); err != nil {
+		return nil, err
+	}
+
+	return response.Bytes(), nil
+}
@@ -3,11 +3,17 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 
+	"github.com/ozontech/http-ringpop/pkg/breaker"
+	"github.com/ozontech/http-ringpop/pkg/hedge"
+	"github.com/ozontech/http-ringpop/pkg/hotkey"
 	"github.com/ozontech/http-ringpop/pkg/metrics"
+	"github.com/ozontech/http-ringpop/pkg/route"
 	"github.com/ozontech/http-ringpop/ring"
 
 	"github.com/uber-common/bark"
@@ -24,6 +30,7 @@ var (
 	metricHTTPRequestsTotal               = metrics.MustRegisterCounter("http_requests_total", "Total number of received HTTP requests")
 	metricRequestsForwardedToBackendTotal = metrics.MustRegisterCounter("requests_forwarded_to_backend_total", "Total number of requests forwarded to HTTP backend")
 	metricRequestsForwardedToRingpopTotal = metrics.MustRegisterCounter("requests_forwarded_to_ringpop_total", "Total number of requests forwarded to ringpop")
+	metricHotKeyRequestsSpilledTotal      = metrics.MustRegisterCounter("hot_key_requests_spilled_total", "Total number of hot key requests sent to successors of key owner")
 )
 
 // NewServer returns new HTTPServer
@@ -42,6 +49,43 @@ type HTTPServer struct {
 	requestForwarder ring.Forwarder
 	backend          http.Handler
 	logger           bark.Logger
+
+	routes         *route.Table
+	hotKeys        *hotkey.Tracker
+	hotKeyReplicas int
+
+	loads          *ring.LoadTracker
+	resolveOptions []ring.ResolveOption
+
+	hedger *hedge.Hedger
+}
+
+// WithRoutes sets per route settings
+func (srv *HTTPServer) WithRoutes(routes *route.Table) *HTTPServer {
+	srv.routes = routes
+	return srv
+}
+
+// WithHotKeys enables spreading read requests of hot keys among given number
+// of nodes (key owner and its successors), it works for routes with read replicas only
+func (srv *HTTPServer) WithHotKeys(tracker *hotkey.Tracker, replicas int) *HTTPServer {
+	srv.hotKeys = tracker
+	srv.hotKeyReplicas = replicas
+	return srv
+}
+
+// WithResolveOptions changes the way destination node is resolved
+func (srv *HTTPServer) WithResolveOptions(options ...ring.ResolveOption) *HTTPServer {
+	srv.resolveOptions = append(srv.resolveOptions, options...)
+	return srv
+}
+
+// WithBoundedLoad enables consistent hashing with bounded loads,
+// load of nodes is estimated by requests in progress sent by this node
+func (srv *HTTPServer) WithBoundedLoad(loads *ring.LoadTracker, epsilon float64) *HTTPServer {
+	srv.loads = loads
+	srv.resolveOptions = append(srv.resolveOptions, ring.WithBoundedLoad(loads, epsilon))
+	return srv
 }
 
 // Handle
@@ -50,8 +94,11 @@ func (srv *HTTPServer) Handle(w http.ResponseWriter, r *http.Request) {
 
 	key := ring.RequestToKey(r)
 	srv.logger.Infof("Got request. Key: %s", key)
+	r.Header.Set(ring.HeaderKey, key)
 
-	dstNode, err := ring.ResolveDestinationNode(srv.ringpop, key)
+	// With bounded loads request is accounted in load of destination node until response is written
+	dstNode, done, err := ring.ReserveDestinationNode(srv.ringpop, key, srv.resolveOptions...)
+	defer func() { done() }()
 	if err != nil {
 		srv.logger.Errorf("Can't resolve dst node: %v", err)
 		fmt.Fprintf(w, "Can't resolve dst node: %s", err)
@@ -68,6 +115,13 @@ func (srv *HTTPServer) Handle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(headerRingpopReceivedBy, address)
 	r.Header.Set(headerRingpopReceivedBy, address) // Just to know on dst node who was first receiver
 
+	if srv.hotKeys != nil && srv.routes.Match(r).IsReplicatedRead(r) && srv.hotKeys.IsHot(key) {
+		if node := srv.spillOver(key, dstNode); node != dstNode {
+			done()
+			dstNode, done = node, srv.loads.Start(node)
+		}
+	}
+
 	shouldHandle := address == dstNode
 
 	if shouldHandle {
@@ -76,8 +130,12 @@ func (srv *HTTPServer) Handle(w http.ResponseWriter, r *http.Request) {
 		r.Header.Set(headerProxy, address)
 		w.Header().Set(headerRingpopHandledBy, address)
 
-		// ServeHTTP request on this instance
-		srv.backend.ServeHTTP(w, r)
+		// ServeHTTP request on this instance, hot key is reported to nodes forwarding requests only
+		var backendWriter http.ResponseWriter = w
+		if srv.hotKeys != nil {
+			backendWriter = &stripHeaderWriter{ResponseWriter: w, header: hotkey.Header}
+		}
+		srv.backend.ServeHTTP(backendWriter, r)
 
 		metricRequestsForwardedToBackendTotal.Inc()
 
@@ -87,10 +145,29 @@ func (srv *HTTPServer) Handle(w http.ResponseWriter, r *http.Request) {
 	srv.logger.Infof("Request will be handled on another node: %v", dstNode)
 
 	// Forward request to responsible host
-	srv.forwardRequestToDstNode(dstNode, key, w, r)
+	srv.forwardRequestToDstNode(address, dstNode, key, w, r)
+}
+
+// spillOver picks random node among key owner and its successors to spread requests of hot key
+func (srv *HTTPServer) spillOver(key, dstNode string) string {
+	nodes, err := ring.ResolveDestinationNodes(srv.ringpop, key, srv.hotKeyReplicas, srv.resolveOptions...)
+	if err != nil {
+		srv.logger.Errorf("Can't resolve successors of hot key: %v", err)
+		return dstNode
+	}
+
+	node := nodes[rand.Intn(len(nodes))]
+	if node != dstNode {
+		srv.logger.Infof("Request of hot key %s will be handled by successor %s instead of %s", key, node, dstNode)
+		metricHotKeyRequestsSpilledTotal.Inc()
+	}
+
+	return node
 }
 
-func (srv *HTTPServer) forwardRequestToDstNode(dstNode, key string, w http.ResponseWriter, r *http.Request) {
+func (srv *HTTPServer) forwardRequestToDstNode(address, dstNode, key string, w http.ResponseWriter, r *http.Request) {
+	hedged := srv.hedger != nil && srv.routes.Match(r).IsReplicatedRead(r)
+
 	// Override request host (it doesn't affect anything, just for consistency)
 	r.Host = dstNode
 
@@ -101,8 +178,17 @@ func (srv *HTTPServer) forwardRequestToDstNode(dstNode, key string, w http.Respo
 		return
 	}
 
-	rawResponse, err := srv.requestForwarder.Forward(dstNode, key, requestBytes)
+	var rawResponse []byte
+	if hedged {
+		result := srv.forwardHedged(r.Context(), address, dstNode, key, requestBytes)
+		rawResponse, err, dstNode = result.response, result.err, result.node
+	} else {
+		rawResponse, err = srv.requestForwarder.Forward(dstNode, key, requestBytes)
+	}
 	if err != nil {
+		if errors.Is(err, breaker.ErrOpen) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
 		fmt.Fprintf(w, "Unable to forward request: %v", err)
 		srv.logger.Errorf("Unable to forward request: %v", err)
 		return
@@ -112,11 +198,17 @@ func (srv *HTTPServer) forwardRequestToDstNode(dstNode, key string, w http.Respo
 
 	w.Header().Set(headerRingpopHandledBy, dstNode)
 
-	if err := copyHTTPResponseFromRaw(w, r, rawResponse); err != nil {
+	header, err := copyHTTPResponseFromRaw(w, r, rawResponse)
+	if err != nil {
 		fmt.Fprintf(w, "Unable to copy response from raw: %v", err)
 		srv.logger.Errorf("Unable to copy response from raw: %v", err)
 		return
 	}
+
+	// Key owner reports hot keys, so next requests could be spread among its successors
+	if srv.hotKeys != nil && header.Get(hotkey.Header) != "" {
+		srv.hotKeys.Mark(key)
+	}
 }
 
 func httpRequestToBytes(r *http.Request) ([]byte, error) {
@@ -129,25 +221,65 @@ func httpRequestToBytes(r *http.Request) ([]byte, error) {
 	return request.Bytes(), nil
 }
 
-// copyHTTPResponseFromRaw copies data from rawResponse to responseWriter
-func copyHTTPResponseFromRaw(w http.ResponseWriter, r *http.Request, rawResponse []byte) error {
+// copyHTTPResponseFromRaw copies data from rawResponse to responseWriter except internal headers,
+// it returns all headers of response
+func copyHTTPResponseFromRaw(w http.ResponseWriter, r *http.Request, rawResponse []byte) (http.Header, error) {
 	buf := bufio.NewReader(bytes.NewReader(rawResponse))
 	resp, err := http.ReadResponse(buf, r)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for k := range resp.Header {
+		if k == hotkey.Header {
+			continue
+		}
 		w.Header().Set(k, resp.Header.Get(k))
 	}
 
 	w.WriteHeader(resp.StatusCode)
 	w.Write(body)
 
-	return nil
+	return resp.Header, nil
+}
+
+// stripHeaderWriter removes internal response header before it's sent to client
+type stripHeaderWriter struct {
+	http.ResponseWriter
+	header      string
+	wroteHeader bool
+}
+
+func (w *stripHeaderWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.Header().Del(w.header)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *stripHeaderWriter) Write(body []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(body)
+}
+
+func (w *stripHeaderWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns underlying ResponseWriter, http.ResponseController uses it to hijack upgraded connections
+func (w *stripHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
@@ -12,16 +12,22 @@ Content-Length: 50
 Content-Type: text/plain; charset=utf-8
 Date: Tue, 06 Nov 2018 20:59:14 GMT
 X-Proxy: ringpop
+X-Ringpop-Hot-Key: 1
 
 Hello from backend :4001 to client 127.0.0.1:50245`)
 
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest("GET", "http://localhost/", nil)
 
-	if err := copyHTTPResponseFromRaw(w, r, rawBackendResponse); err != nil {
+	header, err := copyHTTPResponseFromRaw(w, r, rawBackendResponse)
+	if err != nil {
 		t.Fatalf("Error on coping HTTP response: %v", err)
 	}
 
+	if w.Header().Get("X-Ringpop-Hot-Key") != "" || header.Get("X-Ringpop-Hot-Key") != "1" {
+		t.Fatal("Internal header must be returned to forwarding node, but not to client")
+	}
+
 	if w.Code != 201 {
 		t.Fatalf("Unexpecetd response code: %d, expected: 201", w.Code)
 	}
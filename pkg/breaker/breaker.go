@@ -0,0 +1,308 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+// State is a state of circuit breaker
+type State int
+
+const (
+	// Closed breaker passes all requests
+	Closed State = iota
+	// HalfOpen breaker passes limited number of probe requests
+	HalfOpen
+	// Open breaker rejects all requests
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	}
+
+	return "unknown"
+}
+
+// ErrOpen is returned when request is rejected by circuit breaker
+var ErrOpen = errors.New("circuit breaker is open")
+
+var (
+	metricCircuitBreakerState = metrics.MustRegisterGaugeVec(
+		"circuit_breaker_state", "State of circuit breaker: 0 - closed, 1 - half-open, 2 - open", "breaker",
+	)
+	metricCircuitBreakerRejectedTotal = metrics.MustRegisterCounterVec(
+		"circuit_breaker_rejected_total", "Total number of requests rejected by circuit breaker", "breaker",
+	)
+)
+
+// Config describes when circuit breaker trips and recovers
+type Config struct {
+	// ConsecutiveFailures opens breaker after given number of failures in a row, zero disables the rule
+	ConsecutiveFailures int
+	// FailureRatio opens breaker when ratio of failures in Window exceeds given value, zero disables the rule
+	FailureRatio float64
+	// MinRequests is a minimum number of requests in Window before FailureRatio is taken into account
+	MinRequests int
+	// Window is a period after which counters of closed breaker are reset
+	Window time.Duration
+	// OpenTimeout is a period of time open breaker rejects requests before probing,
+	// half-open breaker with probes unfinished after it opens again
+	OpenTimeout time.Duration
+	// HalfOpenRequests is a number of successful probes required to close breaker
+	HalfOpenRequests int
+}
+
+// Enabled returns whether config has at least one rule that can open breaker
+func (c Config) Enabled() bool {
+	return c.ConsecutiveFailures > 0 || c.FailureRatio > 0
+}
+
+// Breaker is a circuit breaker, safe for concurrent use
+type Breaker struct {
+	name   string
+	config Config
+	now    func() time.Time
+
+	mu                  sync.Mutex
+	state               State
+	requests            int
+	failures            int
+	consecutiveFailures int
+	windowStart         time.Time
+	changedAt           time.Time
+	// generation is changed with every state, results of requests of older generations are ignored
+	generation     uint64
+	probes         int
+	probeSuccesses int
+	// removed breaker doesn't report metrics, its series are deleted
+	removed bool
+}
+
+// New returns new closed circuit breaker, name is used in metrics
+func New(name string, cfg Config) *Breaker {
+	if cfg.HalfOpenRequests < 1 {
+		cfg.HalfOpenRequests = 1
+	}
+
+	b := &Breaker{
+		name:   name,
+		config: cfg,
+		now:    time.Now,
+	}
+	b.windowStart = b.now()
+	metricCircuitBreakerState.WithLabelValues(name).Set(float64(Closed))
+
+	return b
+}
+
+// Name returns name of circuit breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns current state of circuit breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+
+	return b.state
+}
+
+// Allow checks whether request could be done. If it could, returned func
+// must be called with the result of the request.
+func (b *Breaker) Allow() (func(success bool), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+
+	switch b.state {
+	case Open:
+		b.reportRejected()
+		return nil, ErrOpen
+	case HalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			b.reportRejected()
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(success bool) {
+		b.done(generation, success)
+	}, nil
+}
+
+func (b *Breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Result of request started in another state is not relevant anymore
+	if generation != b.generation {
+		return
+	}
+
+	if b.state == HalfOpen {
+		if !success {
+			b.setState(Open)
+			return
+		}
+
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenRequests {
+			b.setState(Closed)
+		}
+		return
+	}
+
+	b.requests++
+	if success {
+		b.consecutiveFailures = 0
+		return
+	}
+
+	b.failures++
+	b.consecutiveFailures++
+
+	if b.shouldTrip() {
+		b.setState(Open)
+	}
+}
+
+func (b *Breaker) shouldTrip() bool {
+	if b.config.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.config.ConsecutiveFailures {
+		return true
+	}
+
+	if b.config.FailureRatio > 0 && b.requests >= b.config.MinRequests {
+		return float64(b.failures)/float64(b.requests) >= b.config.FailureRatio
+	}
+
+	return false
+}
+
+// refresh moves open breaker to half-open when timeout expires, half-open breaker with probes
+// which haven't finished in time (e.g. handler panicked or backend hangs) back to open,
+// and resets counting window
+func (b *Breaker) refresh() {
+	now := b.now()
+
+	switch b.state {
+	case Open:
+		if now.Sub(b.changedAt) >= b.config.OpenTimeout {
+			b.setState(HalfOpen)
+		}
+	case HalfOpen:
+		if b.probes > b.probeSuccesses && now.Sub(b.changedAt) >= b.config.OpenTimeout {
+			b.setState(Open)
+		}
+	case Closed:
+		if b.config.Window > 0 && now.Sub(b.windowStart) >= b.config.Window {
+			b.resetCounts()
+		}
+	}
+}
+
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.generation++
+	b.changedAt = b.now()
+	b.resetCounts()
+
+	if !b.removed {
+		metricCircuitBreakerState.WithLabelValues(b.name).Set(float64(state))
+	}
+}
+
+func (b *Breaker) reportRejected() {
+	if !b.removed {
+		metricCircuitBreakerRejectedTotal.WithLabelValues(b.name).Inc()
+	}
+}
+
+// remove deletes metric series of breaker, requests still in progress don't create them again
+func (b *Breaker) remove() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removed = true
+	metricCircuitBreakerState.DeleteLabelValues(b.name)
+	metricCircuitBreakerRejectedTotal.DeleteLabelValues(b.name)
+}
+
+func (b *Breaker) resetCounts() {
+	b.requests = 0
+	b.failures = 0
+	b.consecutiveFailures = 0
+	b.probes = 0
+	b.probeSuccesses = 0
+	b.windowStart = b.now()
+}
+
+// Group is a set of circuit breakers with the same config created on demand, e.g. per destination node
+type Group struct {
+	prefix string
+	config Config
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup returns new group of circuit breakers, prefix is prepended to breaker names
+func NewGroup(prefix string, cfg Config) *Group {
+	return &Group{
+		prefix:   prefix,
+		config:   cfg,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get returns circuit breaker by name, creating it if needed
+func (g *Group) Get(name string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.breakers[name]
+	if !ok {
+		b = New(g.prefix+name, g.config)
+		g.breakers[name] = b
+	}
+
+	return b
+}
+
+// Retain removes circuit breakers (and their metric series) except given ones,
+// e.g. breakers of nodes which left the ring
+func (g *Group) Retain(names ...string) {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	g.mu.Lock()
+	var removed []*Breaker
+	for name, b := range g.breakers {
+		if !keep[name] {
+			removed = append(removed, b)
+			delete(g.breakers, name)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, b := range removed {
+		b.remove()
+	}
+}
@@ -0,0 +1,154 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestBreaker(cfg Config) (*Breaker, *time.Time) {
+	now := time.Unix(0, 0)
+	b := New("test", cfg)
+	b.now = func() time.Time { return now }
+	b.windowStart = now
+
+	return b, &now
+}
+
+func call(t *testing.T, b *Breaker, success bool) error {
+	t.Helper()
+
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	done(success)
+
+	return nil
+}
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	b, now := newTestBreaker(Config{ConsecutiveFailures: 3, OpenTimeout: time.Second, HalfOpenRequests: 2})
+
+	for i := 0; i < 3; i++ {
+		call(t, b, false)
+	}
+
+	if b.State() != Open {
+		t.Fatalf("Unexpected state: %s, expected: open", b.State())
+	}
+	if err := call(t, b, true); err != ErrOpen {
+		t.Fatalf("Unexpected error: %v, expected: %v", err, ErrOpen)
+	}
+
+	*now = now.Add(time.Second)
+	if b.State() != HalfOpen {
+		t.Fatalf("Unexpected state: %s, expected: half-open", b.State())
+	}
+
+	// Only HalfOpenRequests probes are allowed at once
+	done1, err1 := b.Allow()
+	done2, err2 := b.Allow()
+	if _, err := b.Allow(); err1 != nil || err2 != nil || err != ErrOpen {
+		t.Fatalf("Unexpected probes: %v, %v, %v", err1, err2, err)
+	}
+
+	done1(true)
+	done2(true)
+
+	if b.State() != Closed {
+		t.Fatalf("Unexpected state: %s, expected: closed", b.State())
+	}
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	b, now := newTestBreaker(Config{ConsecutiveFailures: 1, OpenTimeout: time.Second})
+
+	call(t, b, false)
+	*now = now.Add(time.Second)
+	call(t, b, false)
+
+	if b.State() != Open {
+		t.Fatalf("Unexpected state: %s, expected: open", b.State())
+	}
+}
+
+func TestBreakerHalfOpenUnfinishedProbe(t *testing.T) {
+	b, now := newTestBreaker(Config{ConsecutiveFailures: 1, OpenTimeout: time.Second})
+
+	call(t, b, false)
+	*now = now.Add(time.Second)
+
+	// Probe never reports its result, e.g. handler panicked
+	lost, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Unexpected error of probe: %v", err)
+	}
+	if err := call(t, b, true); err != ErrOpen {
+		t.Fatalf("Unexpected error: %v, expected: %v", err, ErrOpen)
+	}
+
+	*now = now.Add(time.Second)
+	if b.State() != Open {
+		t.Fatalf("Unexpected state: %s, expected: open", b.State())
+	}
+
+	*now = now.Add(time.Second)
+	if err := call(t, b, true); err != nil {
+		t.Fatalf("Unexpected error of the next probe: %v", err)
+	}
+	if b.State() != Closed {
+		t.Fatalf("Unexpected state: %s, expected: closed", b.State())
+	}
+
+	// Result of the lost probe is too late
+	lost(false)
+	if b.State() != Closed {
+		t.Fatalf("Unexpected state: %s, expected: closed", b.State())
+	}
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	b, now := newTestBreaker(Config{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute, OpenTimeout: time.Second})
+
+	call(t, b, false)
+	call(t, b, false)
+	if b.State() != Closed {
+		t.Fatalf("Breaker must stay closed until MinRequests, got: %s", b.State())
+	}
+
+	// Counters are reset with a new window
+	*now = now.Add(time.Minute)
+	call(t, b, true)
+	call(t, b, true)
+	call(t, b, true)
+	call(t, b, false)
+	if b.State() != Closed {
+		t.Fatalf("Unexpected state: %s, expected: closed", b.State())
+	}
+
+	call(t, b, false)
+	call(t, b, false)
+	if b.State() != Open {
+		t.Fatalf("Unexpected state: %s, expected: open", b.State())
+	}
+}
+
+func TestGroupRetain(t *testing.T) {
+	g := NewGroup("node:", Config{ConsecutiveFailures: 1, OpenTimeout: time.Second})
+
+	kept := g.Get("10.0.0.1:5000")
+	removed := g.Get("10.0.0.2:5000")
+	call(t, removed, false)
+
+	g.Retain("10.0.0.1:5000")
+
+	if g.Get("10.0.0.1:5000") != kept {
+		t.Fatal("Breaker of retained node is recreated")
+	}
+	if b := g.Get("10.0.0.2:5000"); b == removed || b.State() != Closed {
+		t.Fatal("Breaker of removed node is kept")
+	}
+	if !removed.removed {
+		t.Fatal("Metrics of removed breaker aren't deleted")
+	}
+}
@@ -0,0 +1,30 @@
+package breaker
+
+import (
+	"net/http"
+
+	"github.com/ozontech/http-ringpop/pkg/status"
+)
+
+// Handler returns handler protected by circuit breaker.
+// Responses with 5xx status code are considered as failures,
+// requests rejected by circuit breaker get 503 immediately.
+func Handler(b *Breaker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done, err := b.Allow()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		// Request which panicked (e.g. aborted by proxy) is a failure, result is reported anyway
+		var finished bool
+		recorder := status.NewRecorder(w)
+		defer func() {
+			done(finished && recorder.Status() < http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(recorder, r)
+		finished = true
+	})
+}
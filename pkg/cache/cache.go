@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+var (
+	metricCacheSizeBytes      = metrics.MustRegisterGauge("cache_size_bytes", "Size of cached responses")
+	metricCacheEntries        = metrics.MustRegisterGauge("cache_entries", "Number of cached responses")
+	metricCacheEvictionsTotal = metrics.MustRegisterCounter("cache_evictions_total", "Total number of cached responses evicted to free space")
+)
+
+// Config describes limits of cache
+type Config struct {
+	// MaxBytes is a maximum size of all cached responses, the least recently used ones are evicted
+	MaxBytes int64
+	// MaxEntryBytes is a maximum size of single cached response
+	MaxEntryBytes int64
+	// StaleWhileRevalidate is used when response has no stale-while-revalidate directive
+	StaleWhileRevalidate time.Duration
+}
+
+// Cache is an in-memory LRU storage of HTTP responses, safe for concurrent use.
+// Responses of the same URL that vary by request headers are stored as separate variants.
+type Cache struct {
+	config Config
+	now    func() time.Time
+
+	mu           sync.Mutex
+	resources    map[string]*resource
+	lru          *list.List
+	size         int64
+	revalidating map[string]bool
+}
+
+// resource is a set of cached variants of URL
+type resource struct {
+	// vary is a list of request headers from the last stored response Vary header
+	vary     []string
+	variants map[string]*list.Element
+}
+
+type entry struct {
+	url     string
+	variant string
+
+	status int
+	header http.Header
+	body   []byte
+
+	// stored is a time when response was generated, it takes upstream Age into account
+	stored time.Time
+	ttl    time.Duration
+	stale  time.Duration
+	size   int64
+}
+
+// Stats describes cache usage
+type Stats struct {
+	Entries  int   `json:"entries"`
+	Size     int64 `json:"size"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// New returns new cache
+func New(cfg Config) *Cache {
+	return &Cache{
+		config:       cfg,
+		now:          time.Now,
+		resources:    make(map[string]*resource),
+		lru:          list.New(),
+		revalidating: make(map[string]bool),
+	}
+}
+
+// get returns cached response for request or nil
+func (c *Cache) get(r *http.Request) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	url := r.URL.RequestURI()
+	res, ok := c.resources[url]
+	if !ok {
+		return nil
+	}
+
+	el, ok := res.variants[variantKey(res.vary, r)]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+
+	return el.Value.(*entry)
+}
+
+// set stores response of request
+func (c *Cache) set(r *http.Request, e *entry, vary []string) {
+	e.url = r.URL.RequestURI()
+	e.variant = variantKey(vary, r)
+	e.size = entrySize(e)
+	if e.size > c.config.MaxEntryBytes || e.size > c.config.MaxBytes {
+		c.Purge(e.url)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if res, ok := c.resources[e.url]; ok {
+		if !equalFold(res.vary, vary) {
+			// Variants of different Vary can't be matched anymore
+			c.removeResource(e.url)
+		} else if el, ok := res.variants[e.variant]; ok {
+			c.removeElement(el)
+		}
+	}
+
+	res, ok := c.resources[e.url]
+	if !ok {
+		res = &resource{vary: vary, variants: make(map[string]*list.Element)}
+		c.resources[e.url] = res
+	}
+	res.variants[e.variant] = c.lru.PushFront(e)
+	c.size += e.size
+
+	for c.size > c.config.MaxBytes {
+		c.removeElement(c.lru.Back())
+		metricCacheEvictionsTotal.Inc()
+	}
+
+	c.updateMetrics()
+}
+
+// Purge removes cached responses of given URL (path with query), returns number of removed responses
+func (c *Cache) Purge(url string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.updateMetrics()
+
+	return c.removeResource(url)
+}
+
+// PurgePrefix removes cached responses of URLs starting with given prefix, returns number of removed responses
+func (c *Cache) PurgePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.updateMetrics()
+
+	var removed int
+	for url := range c.resources {
+		if strings.HasPrefix(url, prefix) {
+			removed += c.removeResource(url)
+		}
+	}
+
+	return removed
+}
+
+// Stats returns cache usage
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Entries:  c.lru.Len(),
+		Size:     c.size,
+		MaxBytes: c.config.MaxBytes,
+	}
+}
+
+// startRevalidation returns false if cached response is being revalidated already
+func (c *Cache) startRevalidation(e *entry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := e.url + "\n" + e.variant
+	if c.revalidating[key] {
+		return false
+	}
+	c.revalidating[key] = true
+
+	return true
+}
+
+func (c *Cache) finishRevalidation(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.revalidating, e.url+"\n"+e.variant)
+}
+
+func (c *Cache) removeResource(url string) int {
+	res, ok := c.resources[url]
+	if !ok {
+		return 0
+	}
+
+	removed := len(res.variants)
+	for _, el := range res.variants {
+		c.removeElement(el)
+	}
+
+	return removed
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	c.size -= e.size
+
+	res := c.resources[e.url]
+	delete(res.variants, e.variant)
+	if len(res.variants) == 0 {
+		delete(c.resources, e.url)
+	}
+}
+
+func (c *Cache) updateMetrics() {
+	metricCacheSizeBytes.Set(float64(c.size))
+	metricCacheEntries.Set(float64(c.lru.Len()))
+}
+
+// fresh returns true if response could be served without revalidation
+func (e *entry) fresh(now time.Time) bool {
+	return e.age(now) < e.ttl
+}
+
+// usableStale returns true if stale response could be served while it's revalidated in background
+func (e *entry) usableStale(now time.Time) bool {
+	return e.age(now) < e.ttl+e.stale
+}
+
+func (e *entry) age(now time.Time) time.Duration {
+	return now.Sub(e.stored)
+}
+
+// variantKey returns values of request headers listed in Vary
+func variantKey(vary []string, r *http.Request) string {
+	var b strings.Builder
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// entrySize estimates memory used by cached response
+func entrySize(e *entry) int64 {
+	size := len(e.url) + len(e.variant) + len(e.body)
+	for name, values := range e.header {
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+
+	return int64(size)
+}
+
+func equalFold(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
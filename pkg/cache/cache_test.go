@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/ratelimit"
+	"github.com/ozontech/http-ringpop/pkg/route"
+)
+
+type testBackend struct {
+	calls   int
+	header  http.Header
+	body    string
+	etag    string
+	handled chan struct{}
+}
+
+func (b *testBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.calls++
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+
+	if b.etag != "" {
+		w.Header().Set("ETag", b.etag)
+		if r.Header.Get("If-None-Match") == b.etag {
+			w.WriteHeader(http.StatusNotModified)
+			b.done()
+			return
+		}
+	}
+
+	fmt.Fprint(w, b.body, b.calls)
+	b.done()
+}
+
+func (b *testBackend) done() {
+	if b.handled != nil {
+		b.handled <- struct{}{}
+	}
+}
+
+func newTestCache(now *time.Time) *Cache {
+	c := New(Config{MaxBytes: 1 << 20, MaxEntryBytes: 1 << 10})
+	c.now = func() time.Time { return *now }
+
+	return c
+}
+
+func get(h http.Handler, url string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func expectResponse(t *testing.T, w *httptest.ResponseRecorder, result, body string) {
+	t.Helper()
+
+	if got := w.Header().Get(Header); got != result {
+		t.Fatalf("Unexpected cache result: %s, expected: %s", got, result)
+	}
+	if got := w.Body.String(); got != body {
+		t.Fatalf("Unexpected body: %q, expected: %q", got, body)
+	}
+}
+
+func TestHandlerMaxAge(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}}, body: "response"}
+	h := Handler(newTestCache(&now), backend)
+
+	expectResponse(t, get(h, "/a"), resultMiss, "response1")
+	expectResponse(t, get(h, "/a"), resultHit, "response1")
+	expectResponse(t, get(h, "/b"), resultMiss, "response2")
+
+	now = now.Add(11 * time.Second)
+	expectResponse(t, get(h, "/a"), resultMiss, "response3")
+}
+
+func TestHandlerKeepsOuterHeaders(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}, "Content-Type": {"text/plain"}}, body: "response"}
+	routes := &route.Table{Rules: []*route.Rule{{
+		Name:      "api",
+		RateLimit: &route.RateLimit{Limit: 10, Period: route.Duration(time.Minute)},
+	}}}
+	key := func(r *http.Request) string { return r.URL.Path }
+	h := ratelimit.Handler(ratelimit.NewLimiter(), routes, key, Handler(newTestCache(&now), backend))
+
+	for i, result := range []string{resultMiss, resultHit, resultHit} {
+		w := get(h, "/a")
+		expectResponse(t, w, result, "response1")
+
+		if got, expected := w.Header().Get("RateLimit-Remaining"), fmt.Sprint(9-i); got != expected {
+			t.Fatalf("Unexpected RateLimit-Remaining of %s response: %s, expected: %s", result, got, expected)
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/plain" {
+			t.Fatalf("Unexpected Content-Type of %s response: %s", result, got)
+		}
+	}
+}
+
+func TestHandlerNotCacheable(t *testing.T) {
+	now := time.Now()
+	for _, header := range []http.Header{
+		{},
+		{"Cache-Control": {"no-store"}},
+		{"Cache-Control": {"private, max-age=10"}},
+		{"Cache-Control": {"max-age=10"}, "Set-Cookie": {"a=b"}},
+		{"Cache-Control": {"max-age=10"}, "Vary": {"*"}},
+	} {
+		backend := &testBackend{header: header, body: "response"}
+		h := Handler(newTestCache(&now), backend)
+
+		get(h, "/")
+		get(h, "/")
+		if backend.calls != 2 {
+			t.Fatalf("Response with headers %v must not be cached", header)
+		}
+	}
+}
+
+func TestHandlerVary(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}, "Vary": {"Accept-Language"}}, body: "response"}
+	h := Handler(newTestCache(&now), backend)
+
+	expectResponse(t, get(h, "/", "Accept-Language", "en"), resultMiss, "response1")
+	expectResponse(t, get(h, "/", "Accept-Language", "ru"), resultMiss, "response2")
+	expectResponse(t, get(h, "/", "Accept-Language", "en"), resultHit, "response1")
+	expectResponse(t, get(h, "/", "Accept-Language", "ru"), resultHit, "response2")
+}
+
+func TestHandlerRevalidation(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}}, body: "response", etag: `"v1"`}
+	h := Handler(newTestCache(&now), backend)
+
+	expectResponse(t, get(h, "/"), resultMiss, "response1")
+
+	now = now.Add(11 * time.Second)
+	expectResponse(t, get(h, "/"), resultRevalidated, "response1")
+	expectResponse(t, get(h, "/"), resultHit, "response1")
+
+	// Client validators are answered from cache
+	if w := get(h, "/", "If-None-Match", `"v1"`); w.Code != http.StatusNotModified {
+		t.Fatalf("Unexpected status: %d, expected: %d", w.Code, http.StatusNotModified)
+	}
+
+	backend.etag = `"v2"`
+	now = now.Add(11 * time.Second)
+	expectResponse(t, get(h, "/"), resultMiss, "response3")
+	expectResponse(t, get(h, "/"), resultHit, "response3")
+}
+
+func TestHandlerStaleWhileRevalidate(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{
+		header:  http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=5"}},
+		body:    "response",
+		handled: make(chan struct{}, 1),
+	}
+	h := Handler(newTestCache(&now), backend)
+
+	expectResponse(t, get(h, "/"), resultMiss, "response1")
+	<-backend.handled
+
+	now = now.Add(12 * time.Second)
+	expectResponse(t, get(h, "/"), resultStale, "response1")
+
+	// Wait for background revalidation
+	<-backend.handled
+	for i := 0; i < 100; i++ {
+		if w := get(h, "/"); w.Header().Get(Header) == resultHit {
+			expectResponse(t, w, resultHit, "response2")
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Stale response was not revalidated")
+}
+
+func TestHandlerRequestNoCache(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}}, body: "response"}
+	h := Handler(newTestCache(&now), backend)
+
+	get(h, "/")
+	expectResponse(t, get(h, "/", "Cache-Control", "no-store"), resultBypass, "response2")
+	expectResponse(t, get(h, "/", "Cache-Control", "no-cache"), resultMiss, "response3")
+	expectResponse(t, get(h, "/"), resultHit, "response3")
+}
+
+func TestCacheEviction(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}}, body: strings.Repeat("x", 100)}
+	c := New(Config{MaxBytes: 500, MaxEntryBytes: 200})
+	c.now = func() time.Time { return now }
+	h := Handler(c, backend)
+
+	for i := 0; i < 10; i++ {
+		get(h, fmt.Sprintf("/%d", i))
+		// Keep the first response recently used
+		get(h, "/0")
+	}
+
+	stats := c.Stats()
+	if stats.Size > 500 || stats.Entries == 0 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if w := get(h, "/0"); w.Header().Get(Header) != resultHit {
+		t.Fatal("Recently used response must not be evicted")
+	}
+	if w := get(h, "/1"); w.Header().Get(Header) != resultMiss {
+		t.Fatal("Least recently used response must be evicted")
+	}
+
+	backend.body = strings.Repeat("x", 300)
+	get(h, "/large")
+	if w := get(h, "/large"); w.Header().Get(Header) != resultMiss {
+		t.Fatal("Response larger than MaxEntryBytes must not be cached")
+	}
+}
+
+func TestPurgeHandler(t *testing.T) {
+	now := time.Now()
+	backend := &testBackend{header: http.Header{"Cache-Control": {"max-age=10"}}, body: "response"}
+	c := newTestCache(&now)
+	h := Handler(c, backend)
+
+	for _, url := range []string{"/a/1", "/a/2", "/b/1"} {
+		get(h, url)
+	}
+
+	w := httptest.NewRecorder()
+	PurgeHandler(c).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?prefix=/a/", nil))
+	if body := strings.TrimSpace(w.Body.String()); body != `{"purged":2}` {
+		t.Fatalf("Unexpected response: %s", body)
+	}
+
+	if w := get(h, "/a/1"); w.Header().Get(Header) != resultMiss {
+		t.Fatal("Purged response must not be served")
+	}
+	if w := get(h, "/b/1"); w.Header().Get(Header) != resultHit {
+		t.Fatal("Response must stay in cache")
+	}
+}
@@ -0,0 +1,471 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+// Header tells client how response was served: HIT, STALE, REVALIDATED, MISS or BYPASS
+const Header = "X-Cache"
+
+const (
+	resultHit         = "HIT"
+	resultStale       = "STALE"
+	resultRevalidated = "REVALIDATED"
+	resultMiss        = "MISS"
+	resultBypass      = "BYPASS"
+)
+
+var (
+	metricCacheRequestsTotal = metrics.MustRegisterCounterVec("cache_requests_total", "Total number of requests passed through cache", "result")
+)
+
+// cacheableStatuses are statuses of responses that could be stored
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// Handler serves GET and HEAD requests from cache, other requests are passed to next handler.
+// Responses are stored according to Cache-Control (or Expires) and Vary headers,
+// stale responses are revalidated with ETag and Last-Modified validators.
+func Handler(c *Cache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		directives := parseCacheControl(r.Header.Get("Cache-Control"))
+		if _, ok := directives["no-store"]; ok {
+			metricCacheRequestsTotal.WithLabelValues(resultBypass).Inc()
+			w.Header().Set(Header, resultBypass)
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, noCache := directives["no-cache"]
+
+		now := c.now()
+		e := c.get(r)
+		switch {
+		case e == nil:
+			c.fetch(w, r, next)
+
+		case !noCache && e.fresh(now):
+			metricCacheRequestsTotal.WithLabelValues(resultHit).Inc()
+			serve(w, r, e, now, resultHit)
+
+		case !noCache && e.usableStale(now):
+			metricCacheRequestsTotal.WithLabelValues(resultStale).Inc()
+			serve(w, r, e, now, resultStale)
+
+			if c.startRevalidation(e) {
+				go func() {
+					defer c.finishRevalidation(e)
+					c.revalidate(r.Clone(context.Background()), e, next)
+				}()
+			}
+
+		case hasValidators(e):
+			if resp := c.revalidate(r, e, next); resp != nil {
+				resp.writeTo(w, r, resultMiss)
+				return
+			}
+			if refreshed := c.get(r); refreshed != nil {
+				e = refreshed
+			}
+			serve(w, r, e, c.now(), resultRevalidated)
+
+		default:
+			c.fetch(w, r, next)
+		}
+	})
+}
+
+// PurgeHandler returns handler that removes cached responses: url parameter purges responses
+// of given URL, prefix parameter purges responses of URLs with given prefix.
+// GET request shows cache stats.
+func PurgeHandler(c *Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, c.Stats())
+			return
+		}
+
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var purged int
+		switch query := r.URL.Query(); {
+		case query.Get("url") != "":
+			purged = c.Purge(query.Get("url"))
+		case query.Has("prefix"):
+			purged = c.PurgePrefix(query.Get("prefix"))
+		default:
+			http.Error(w, "url or prefix parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, struct {
+			Purged int `json:"purged"`
+		}{purged})
+	})
+}
+
+// fetch passes request to next handler and stores response if it's cacheable
+func (c *Cache) fetch(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	metricCacheRequestsTotal.WithLabelValues(resultMiss).Inc()
+	w.Header().Set(Header, resultMiss)
+
+	if r.Method == http.MethodHead {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	recorder := &recorder{ResponseWriter: w, status: http.StatusOK, limit: c.config.MaxEntryBytes}
+	next.ServeHTTP(recorder, r)
+
+	if !recorder.overflow {
+		c.store(r, recorder.response())
+	}
+}
+
+// revalidate sends conditional request to next handler. If cached response is still valid,
+// it's refreshed and nil is returned, otherwise new response is stored (if it's cacheable) and returned.
+func (c *Cache) revalidate(r *http.Request, e *entry, next http.Handler) *response {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+	req.Header.Del("If-Modified-Since")
+	req.Header.Del("If-None-Match")
+	if etag := e.header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if modified := e.header.Get("Last-Modified"); modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+
+	recorder := &recorder{ResponseWriter: newBuffer(), status: http.StatusOK}
+	next.ServeHTTP(recorder, req)
+	resp := recorder.response()
+
+	if resp.status == http.StatusNotModified {
+		refreshed := *e
+		refreshed.header = e.header.Clone()
+		for _, name := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified", "Age"} {
+			if values, ok := resp.header[name]; ok {
+				refreshed.header[name] = values
+			}
+		}
+		c.store(r, &response{status: e.status, header: refreshed.header, body: e.body})
+		metricCacheRequestsTotal.WithLabelValues(resultRevalidated).Inc()
+		return nil
+	}
+
+	metricCacheRequestsTotal.WithLabelValues(resultMiss).Inc()
+	if !c.store(r, resp) {
+		c.Purge(r.URL.RequestURI())
+	}
+
+	return resp
+}
+
+// store saves response if it's cacheable, returns false otherwise
+func (c *Cache) store(r *http.Request, resp *response) bool {
+	if !cacheableStatuses[resp.status] || resp.header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	vary := varyHeaders(resp.header)
+	for _, name := range vary {
+		if name == "*" {
+			return false
+		}
+	}
+
+	directives := parseCacheControl(strings.Join(resp.header.Values("Cache-Control"), ","))
+	if hasDirective(directives, "no-store", "private") {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" && !hasDirective(directives, "public", "s-maxage") {
+		return false
+	}
+
+	now := c.now()
+	ttl, ok := freshness(directives, resp.header, now)
+	if !ok {
+		return false
+	}
+	if _, noCache := directives["no-cache"]; noCache {
+		ttl = 0
+	}
+
+	e := &entry{
+		status: resp.status,
+		header: resp.header.Clone(),
+		body:   resp.body,
+		stored: now,
+		ttl:    ttl,
+		stale:  c.config.StaleWhileRevalidate,
+	}
+	if ttl == 0 && !hasValidators(e) {
+		return false
+	}
+	if age, err := strconv.Atoi(resp.header.Get("Age")); err == nil && age > 0 {
+		e.stored = now.Add(-time.Duration(age) * time.Second)
+	}
+	if stale, ok := seconds(directives, "stale-while-revalidate"); ok {
+		e.stale = stale
+	}
+	if hasDirective(directives, "no-cache", "must-revalidate", "proxy-revalidate") {
+		e.stale = 0
+	}
+	e.header.Del("Age")
+	e.header.Del(Header)
+
+	c.set(r, e, vary)
+
+	return true
+}
+
+// serve writes cached response
+func serve(w http.ResponseWriter, r *http.Request, e *entry, now time.Time, result string) {
+	header := w.Header()
+	copyHeader(header, e.header)
+	header.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+	header.Set(Header, result)
+
+	if etag := e.header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(e.status)
+	if r.Method != http.MethodHead {
+		w.Write(e.body)
+	}
+}
+
+// freshness returns how long response is fresh, s-maxage has priority over max-age and Expires
+func freshness(directives map[string]string, header http.Header, now time.Time) (time.Duration, bool) {
+	if ttl, ok := seconds(directives, "s-maxage"); ok {
+		return ttl, true
+	}
+	if ttl, ok := seconds(directives, "max-age"); ok {
+		return ttl, true
+	}
+
+	if value := header.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil {
+			// Invalid Expires means already expired
+			return 0, true
+		}
+
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			date = now
+		}
+		if ttl := expires.Sub(date); ttl > 0 {
+			return ttl, true
+		}
+		return 0, true
+	}
+
+	_, noCache := directives["no-cache"]
+	return 0, noCache
+}
+
+// parseCacheControl returns Cache-Control directives with their values
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, arg := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, arg = part[:i], strings.Trim(part[i+1:], `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = arg
+	}
+
+	return directives
+}
+
+func seconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * time.Second, true
+}
+
+func hasDirective(directives map[string]string, names ...string) bool {
+	for _, name := range names {
+		if _, ok := directives[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasValidators(e *entry) bool {
+	return e.header.Get("ETag") != "" || e.header.Get("Last-Modified") != ""
+}
+
+// varyHeaders returns canonical names of request headers listed in Vary
+func varyHeaders(header http.Header) []string {
+	var vary []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return vary
+}
+
+// response is a complete response of next handler
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (resp *response) writeTo(w http.ResponseWriter, r *http.Request, result string) {
+	header := w.Header()
+	copyHeader(header, resp.header)
+	header.Set(Header, result)
+
+	w.WriteHeader(resp.status)
+	if r.Method != http.MethodHead {
+		w.Write(resp.body)
+	}
+}
+
+// copyHeader copies stored response headers to dst, headers already set by outer handlers
+// (e.g. rate limit headers) are kept as is
+func copyHeader(dst, src http.Header) {
+	for name, values := range src {
+		if _, ok := dst[name]; ok {
+			continue
+		}
+		dst[name] = append([]string(nil), values...)
+	}
+}
+
+// recorder passes response to underlying ResponseWriter and keeps a copy of it up to limit bytes.
+// Next handler gets its own header map, so only headers it sets are recorded.
+type recorder struct {
+	http.ResponseWriter
+	status        int
+	header        http.Header
+	handlerHeader http.Header
+	body          bytes.Buffer
+	limit         int64
+	overflow      bool
+	wroteHeader   bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	rec.header = rec.Header().Clone()
+
+	header := rec.ResponseWriter.Header()
+	for name, values := range rec.header {
+		header[name] = append([]string(nil), values...)
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Header() http.Header {
+	if rec.handlerHeader == nil {
+		rec.handlerHeader = make(http.Header)
+	}
+
+	return rec.handlerHeader
+}
+
+func (rec *recorder) Write(data []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if !rec.overflow {
+		if rec.limit > 0 && int64(rec.body.Len()+len(data)) > rec.limit {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(data)
+		}
+	}
+
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *recorder) response() *response {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	return &response{
+		status: rec.status,
+		header: rec.header,
+		body:   rec.body.Bytes(),
+	}
+}
+
+// buffer is a ResponseWriter that discards response, it's used with recorder
+// when response must not be sent to client
+type buffer struct {
+	header http.Header
+}
+
+func newBuffer() *buffer {
+	return &buffer{header: make(http.Header)}
+}
+
+func (b *buffer) Header() http.Header {
+	return b.header
+}
+
+func (b *buffer) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (b *buffer) WriteHeader(status int) {}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,125 @@
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+	"github.com/ozontech/http-ringpop/pkg/route"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	metricCoalescedRequestsTotal = metrics.MustRegisterCounterVec("coalesced_requests_total", "Total number of requests that shared backend call with identical concurrent request", "route")
+)
+
+// Handler returns handler that collapses concurrent identical GET and HEAD requests of routes with coalesce rule
+// into one call of next handler. Response of shared call is buffered and copied to every request.
+func Handler(routes *route.Table, next http.Handler) http.Handler {
+	return handler(routes, next, func() {})
+}
+
+// handler calls joined when request has joined its call, it lets tests release backend deterministically
+func handler(routes *route.Table, next http.Handler, joined func()) http.Handler {
+	var group singleflight.Group
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rule := routes.Match(r)
+		if rule == nil || rule.Coalesce == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result := group.DoChan(requestKey(rule, r), func() (interface{}, error) {
+			// Call must not be canceled when the first client goes away, others still wait for it
+			req := r.WithContext(context.Background())
+
+			recorder := newRecorder()
+			next.ServeHTTP(recorder, req)
+
+			return recorder, nil
+		})
+		joined()
+
+		res := <-result
+		if res.Shared {
+			metricCoalescedRequestsTotal.WithLabelValues(rule.Name).Inc()
+		}
+
+		res.Val.(*recorder).writeTo(w)
+	})
+}
+
+// credentialHeaders are always part of request key: responses of different users must not be shared
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// requestKey identifies identical requests of the rule
+func requestKey(rule *route.Rule, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(rule.Name)
+	b.WriteByte('\n')
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range credentialHeaders {
+		b.WriteByte('\n')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	for _, name := range rule.Coalesce.Vary {
+		b.WriteByte('\n')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return b.String()
+}
+
+// recorder keeps the whole response of next handler
+type recorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+}
+
+func (rec *recorder) Write(data []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+
+	return rec.body.Write(data)
+}
+
+// writeTo copies response, it's safe to call concurrently
+func (rec *recorder) writeTo(w http.ResponseWriter) {
+	header := w.Header()
+	for name, values := range rec.header {
+		header[name] = append([]string(nil), values...)
+	}
+
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
@@ -0,0 +1,90 @@
+package coalesce
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ozontech/http-ringpop/pkg/route"
+)
+
+func TestHandler(t *testing.T) {
+	routes := &route.Table{Rules: []*route.Rule{
+		{Name: "coalesced", PathPrefix: "/coalesced", Coalesce: &route.Coalesce{Vary: []string{"Accept"}}},
+		{Name: "default", PathPrefix: "/"},
+	}}
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		path    string
+		accepts []string
+		auth    []string
+		calls   int32
+	}{
+		{name: "identical", method: http.MethodGet, path: "/coalesced", accepts: []string{"a", "a", "a", "a"}, calls: 1},
+		{name: "credentials", method: http.MethodGet, path: "/coalesced", accepts: []string{"a", "a", "a", "a"}, auth: []string{"u1", "u2", "u1", ""}, calls: 3},
+		{name: "vary", method: http.MethodGet, path: "/coalesced", accepts: []string{"a", "b", "a", "b"}, calls: 2},
+		{name: "no rule", method: http.MethodGet, path: "/other", accepts: []string{"a", "a", "a", "a"}, calls: 4},
+		{name: "not idempotent", method: http.MethodPost, path: "/coalesced", accepts: []string{"a", "a", "a", "a"}, calls: 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			release := make(chan struct{})
+			joined := make(chan struct{}, len(tc.accepts))
+			passed := make(chan struct{}, len(tc.accepts))
+			backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				passed <- struct{}{}
+				<-release
+
+				w.Header().Set("X-Accept", r.Header.Get("Accept"))
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, "response")
+			})
+			h := handler(routes, backend, func() { joined <- struct{}{} })
+
+			var wg sync.WaitGroup
+			responses := make([]*httptest.ResponseRecorder, len(tc.accepts))
+			for i, accept := range tc.accepts {
+				r := httptest.NewRequest(tc.method, tc.path, nil)
+				r.Header.Set("Accept", accept)
+				if i < len(tc.auth) && tc.auth[i] != "" {
+					r.Header.Set("Authorization", tc.auth[i])
+				}
+				responses[i] = httptest.NewRecorder()
+
+				wg.Add(1)
+				go func(w *httptest.ResponseRecorder) {
+					defer wg.Done()
+					h.ServeHTTP(w, r)
+				}(responses[i])
+			}
+
+			// Let all requests join their calls (or reach backend if they aren't coalesced) before backend responds
+			for j, p := 0, 0; j < len(tc.accepts) && p < len(tc.accepts); {
+				select {
+				case <-joined:
+					j++
+				case <-passed:
+					p++
+				}
+			}
+			close(release)
+			wg.Wait()
+
+			if calls != tc.calls {
+				t.Fatalf("Unexpected number of backend calls: %d, expected: %d", calls, tc.calls)
+			}
+
+			for i, w := range responses {
+				if w.Code != http.StatusCreated || w.Body.String() != "response" || w.Header().Get("X-Accept") != tc.accepts[i] {
+					t.Fatalf("Unexpected response: %d %v %q", w.Code, w.Header(), w.Body.String())
+				}
+			}
+		})
+	}
+}
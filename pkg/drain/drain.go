@@ -0,0 +1,76 @@
+package drain
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+// pollInterval is a period between checks of in-flight requests while waiting for them
+const pollInterval = 10 * time.Millisecond
+
+var (
+	metricInFlightRequests = metrics.MustRegisterGauge("in_flight_requests", "Number of requests being handled by backend handler")
+	metricDraining         = metrics.MustRegisterGauge("draining", "1 when node is shutting down and drains in-flight requests")
+)
+
+// Tracker counts in-flight requests and keeps draining state of node, safe for concurrent use
+type Tracker struct {
+	inFlight int64
+	draining int32
+}
+
+// New creates tracker of in-flight requests
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Start marks node as draining, it's not ready to receive new requests anymore
+func (t *Tracker) Start() {
+	if atomic.CompareAndSwapInt32(&t.draining, 0, 1) {
+		metricDraining.Set(1)
+	}
+}
+
+// Draining reports whether node is shutting down
+func (t *Tracker) Draining() bool {
+	return atomic.LoadInt32(&t.draining) == 1
+}
+
+// InFlight returns number of requests being handled
+func (t *Tracker) InFlight() int64 {
+	return atomic.LoadInt64(&t.inFlight)
+}
+
+// Wait blocks until all in-flight requests are handled or context is done
+func (t *Tracker) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for t.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (t *Tracker) add(delta int64) {
+	metricInFlightRequests.Set(float64(atomic.AddInt64(&t.inFlight, delta)))
+}
+
+// Handler returns handler that counts in-flight requests of next handler
+func Handler(t *Tracker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.add(1)
+		defer t.add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package drain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTrackerWait(t *testing.T) {
+	tracker := New()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := Handler(tracker, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+	<-started
+
+	tracker.Start()
+	if !tracker.Draining() {
+		t.Fatal("Tracker must be draining after start")
+	}
+	if n := tracker.InFlight(); n != 1 {
+		t.Fatalf("Unexpected number of in-flight requests: %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tracker.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error of waiting for in-flight request: %v", err)
+	}
+
+	close(release)
+	<-done
+
+	if err := tracker.Wait(context.Background()); err != nil {
+		t.Fatalf("Unexpected error of waiting without in-flight requests: %v", err)
+	}
+}
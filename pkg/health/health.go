@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// ReadyPath is a path of readiness probe
+	ReadyPath = "/readyz"
+	// LivePath is a path of liveness probe
+	LivePath = "/healthz"
+)
+
+// ErrTimeout is returned when check doesn't complete in time, e.g. it's stuck on a lock
+var ErrTimeout = errors.New("check timed out")
+
+// Check returns nil when component is fine, it should give up when context is done
+type Check func(ctx context.Context) error
+
+// Checker runs named checks, every check is limited by the timeout
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]Check
+}
+
+// Result is a result of all checks
+type Result struct {
+	OK bool `json:"ok"`
+	// Checks maps name of check to "ok" or error
+	Checks map[string]string `json:"checks"`
+}
+
+// New creates checker without checks
+func New(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers check with given name, check with the same name is replaced
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+
+	return c
+}
+
+// Run runs all checks concurrently
+func (c *Checker) Run(ctx context.Context) Result {
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			errs[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	result := Result{OK: true, Checks: make(map[string]string, len(names))}
+	for i, name := range names {
+		if errs[i] != nil {
+			result.OK = false
+			result.Checks[name] = errs[i].Error()
+		} else {
+			result.Checks[name] = "ok"
+		}
+	}
+
+	return result
+}
+
+// run waits for check until context is done, stuck check is left running in background
+func run(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrTimeout
+	}
+}
+
+// Handler returns handler that responds with 200 when all checks pass and with 503 otherwise,
+// result of every check is shown in JSON
+func Handler(c *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := c.Run(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !result.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	failing := errors.New("not bootstrapped")
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	c := New(20 * time.Millisecond)
+	c.Add("ringpop", func(context.Context) error { return nil })
+	h := Handler(c)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status of passing checks: %d", w.Code)
+	}
+
+	c.Add("ringpop", func(context.Context) error { return failing })
+	c.Add("backend", func(context.Context) error {
+		<-stuck
+		return nil
+	})
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Unexpected status of failing checks: %d", w.Code)
+	}
+
+	var result Result
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Unable to decode result: %v", err)
+	}
+	if result.OK || result.Checks["ringpop"] != failing.Error() || result.Checks["backend"] != ErrTimeout.Error() {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+}
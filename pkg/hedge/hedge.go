@@ -0,0 +1,142 @@
+package hedge
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+const (
+	// minSamples is a number of observed latencies required to trust percentile
+	minSamples = 100
+	// recalculateEvery is a number of observations after which delay is recalculated
+	recalculateEvery = 100
+)
+
+var (
+	metricHedgeDelaySeconds             = metrics.MustRegisterGauge("hedge_delay_seconds", "Delay after which hedged request is sent")
+	metricHedgedRequestsBudgetExhausted = metrics.MustRegisterCounter("hedged_requests_budget_exhausted_total", "Total number of hedged requests not sent because budget was exhausted")
+)
+
+// Config describes when hedged request is sent
+type Config struct {
+	// Percentile of observed latencies after which hedged request is sent, e.g. 0.95
+	Percentile float64
+	// Samples is a number of the latest latencies percentile is calculated from
+	Samples int
+	// MinDelay is a lower bound of delay, it protects backends when latencies are tiny
+	MinDelay time.Duration
+	// MaxDelay is an upper bound of delay, it's used until enough latencies are observed
+	MaxDelay time.Duration
+	// Budget is a maximum ratio of hedged requests to all requests
+	Budget float64
+	// BudgetBurst is a number of hedged requests that could be sent at once
+	BudgetBurst int
+}
+
+// Hedger decides when hedged request is sent, safe for concurrent use
+type Hedger struct {
+	config Config
+
+	mu        sync.Mutex
+	samples   []time.Duration
+	next      int
+	observed  int
+	delay     time.Duration
+	tokens    float64
+	maxTokens float64
+}
+
+// New returns new hedger
+func New(cfg Config) *Hedger {
+	if cfg.Samples < minSamples {
+		cfg.Samples = minSamples
+	}
+	if cfg.BudgetBurst < 1 {
+		cfg.BudgetBurst = 1
+	}
+
+	h := &Hedger{
+		config:    cfg,
+		samples:   make([]time.Duration, 0, cfg.Samples),
+		delay:     cfg.MaxDelay,
+		tokens:    float64(cfg.BudgetBurst),
+		maxTokens: float64(cfg.BudgetBurst),
+	}
+	metricHedgeDelaySeconds.Set(h.delay.Seconds())
+
+	return h
+}
+
+// Delay returns time to wait for response before hedged request is sent.
+// Every call accounts request in budget.
+func (h *Hedger) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.tokens = math.Min(h.maxTokens, h.tokens+h.config.Budget)
+
+	return h.delay
+}
+
+// Allow spends budget on hedged request, it returns false when budget is exhausted
+func (h *Hedger) Allow() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tokens < 1 {
+		metricHedgedRequestsBudgetExhausted.Inc()
+		return false
+	}
+	h.tokens--
+
+	return true
+}
+
+// Observe accounts latency of request
+func (h *Hedger) Observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < h.config.Samples {
+		h.samples = append(h.samples, latency)
+	} else {
+		h.samples[h.next] = latency
+		h.next = (h.next + 1) % len(h.samples)
+	}
+
+	if h.observed++; h.observed%recalculateEvery == 0 && len(h.samples) >= minSamples {
+		h.delay = h.percentile()
+		metricHedgeDelaySeconds.Set(h.delay.Seconds())
+	}
+}
+
+// percentile returns configured percentile of samples within delay bounds
+func (h *Hedger) percentile() time.Duration {
+	sorted := make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	i := int(math.Ceil(h.config.Percentile*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	delay := sorted[i]
+	if delay < h.config.MinDelay {
+		delay = h.config.MinDelay
+	}
+	if h.config.MaxDelay > 0 && delay > h.config.MaxDelay {
+		delay = h.config.MaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,51 @@
+package hedge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHedgerDelay(t *testing.T) {
+	h := New(Config{Percentile: 0.9, Samples: 100, MinDelay: 5 * time.Millisecond, MaxDelay: time.Second, Budget: 1})
+
+	if delay := h.Delay(); delay != time.Second {
+		t.Fatalf("Unexpected delay without samples: %v, expected max delay", delay)
+	}
+
+	for i := 1; i <= 100; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+	if delay := h.Delay(); delay != 90*time.Millisecond {
+		t.Fatalf("Unexpected delay: %v, expected: %v", delay, 90*time.Millisecond)
+	}
+
+	// The oldest samples are replaced
+	for i := 0; i < 100; i++ {
+		h.Observe(time.Millisecond)
+	}
+	if delay := h.Delay(); delay != 5*time.Millisecond {
+		t.Fatalf("Unexpected delay: %v, expected min delay", delay)
+	}
+}
+
+func TestHedgerBudget(t *testing.T) {
+	h := New(Config{Percentile: 0.9, Budget: 0.5, BudgetBurst: 2})
+
+	for i := 0; i < 2; i++ {
+		if !h.Allow() {
+			t.Fatal("Burst of hedged requests must be allowed")
+		}
+	}
+	if h.Allow() {
+		t.Fatal("Hedged request must not be allowed when budget is exhausted")
+	}
+
+	h.Delay()
+	if h.Allow() {
+		t.Fatal("Single request earns half of hedged request")
+	}
+	h.Delay()
+	if !h.Allow() {
+		t.Fatal("Two requests earn one hedged request")
+	}
+}
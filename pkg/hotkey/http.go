@@ -0,0 +1,31 @@
+package hotkey
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Header is set by key owner on responses to requests with hot key
+const Header = "X-Ringpop-Hot-Key"
+
+// Handler observes requests on key owner and reports hot keys in response header
+func Handler(t *Tracker, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := key(r)
+
+		t.Observe(k)
+		if t.IsHot(k) {
+			w.Header().Set(Header, "1")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DebugHandler returns handler that shows tracked keys in JSON
+func DebugHandler(t *Tracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Top())
+	})
+}
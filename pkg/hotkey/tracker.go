@@ -0,0 +1,234 @@
+package hotkey
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config describes when key is considered hot
+type Config struct {
+	// Capacity is a number of keys tracked by sketch, only the most frequent keys are kept
+	Capacity int
+	// Window is a period over which request rate is measured
+	Window time.Duration
+	// Threshold is a request rate (per second) that makes key hot
+	Threshold float64
+	// CoolDownRatio keeps hot key hot while its rate is above Threshold * CoolDownRatio.
+	// It prevents flapping when requests of hot key are spread and owner sees only part of them.
+	CoolDownRatio float64
+}
+
+// Stat is a request rate of a key
+type Stat struct {
+	Key string `json:"key"`
+	// Rate is a number of requests per second in the last complete window
+	Rate float64 `json:"rate"`
+	// Requests is a number of requests in the current window
+	Requests uint64 `json:"requests"`
+	// Error is a maximum overestimation of Requests
+	Error uint64 `json:"error"`
+	Hot   bool   `json:"hot"`
+}
+
+// Tracker finds out hot keys using Space-Saving top-k sketch per time window, safe for concurrent use.
+// Key is hot when it's observed often enough by this node or when it's marked as hot by its owner.
+type Tracker struct {
+	config Config
+	now    func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	current     *sketch
+	previous    *sketch
+	hot         map[string]bool
+	marked      map[string]time.Time
+}
+
+// NewTracker returns new hot key tracker
+func NewTracker(cfg Config) *Tracker {
+	if cfg.Capacity < 1 {
+		cfg.Capacity = 1
+	}
+
+	t := &Tracker{
+		config:   cfg,
+		now:      time.Now,
+		current:  newSketch(cfg.Capacity),
+		previous: newSketch(cfg.Capacity),
+		hot:      make(map[string]bool),
+		marked:   make(map[string]time.Time),
+	}
+	t.windowStart = t.now()
+
+	return t
+}
+
+// Observe accounts request with given key
+func (t *Tracker) Observe(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rotate()
+	t.current.add(key)
+}
+
+// Mark remembers key as hot for two windows, it's used when key owner reports hot key
+func (t *Tracker) Mark(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.marked[key] = t.now().Add(2 * t.config.Window)
+}
+
+// IsHot returns whether key is hot
+func (t *Tracker) IsHot(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rotate()
+
+	return t.isHot(key)
+}
+
+// Top returns stats of tracked keys ordered by rate
+func (t *Tracker) Top() []Stat {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rotate()
+
+	stats := make(map[string]*Stat)
+	for key, c := range t.previous.counters {
+		stats[key] = &Stat{Key: key, Rate: t.rate(c.count)}
+	}
+	for key, c := range t.current.counters {
+		stat, ok := stats[key]
+		if !ok {
+			stat = &Stat{Key: key}
+			stats[key] = stat
+		}
+		stat.Requests, stat.Error = c.count, c.err
+	}
+	for key := range t.marked {
+		if _, ok := stats[key]; !ok {
+			stats[key] = &Stat{Key: key}
+		}
+	}
+
+	top := make([]Stat, 0, len(stats))
+	for key, stat := range stats {
+		stat.Hot = t.isHot(key)
+		top = append(top, *stat)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Rate != top[j].Rate {
+			return top[i].Rate > top[j].Rate
+		}
+		return top[i].Requests > top[j].Requests
+	})
+
+	return top
+}
+
+func (t *Tracker) isHot(key string) bool {
+	if t.hot[key] {
+		return true
+	}
+
+	// Key could become hot in the middle of window
+	if c, ok := t.current.counters[key]; ok && t.rate(c.guaranteed()) >= t.config.Threshold {
+		return true
+	}
+
+	expires, ok := t.marked[key]
+	return ok && t.now().Before(expires)
+}
+
+// rotate starts new window when current one is over and recalculates hot keys
+func (t *Tracker) rotate() {
+	now := t.now()
+	elapsed := now.Sub(t.windowStart)
+	if elapsed < t.config.Window {
+		return
+	}
+
+	if elapsed < 2*t.config.Window {
+		t.previous = t.current
+		t.windowStart = t.windowStart.Add(t.config.Window)
+	} else {
+		// There were no requests during the whole window
+		t.previous = newSketch(t.config.Capacity)
+		t.windowStart = now
+	}
+	t.current = newSketch(t.config.Capacity)
+
+	hot := make(map[string]bool)
+	for key, c := range t.previous.counters {
+		rate := t.rate(c.guaranteed())
+		if rate >= t.config.Threshold || t.hot[key] && rate >= t.config.Threshold*t.config.CoolDownRatio {
+			hot[key] = true
+		}
+	}
+	t.hot = hot
+
+	for key, expires := range t.marked {
+		if !now.Before(expires) {
+			delete(t.marked, key)
+		}
+	}
+}
+
+// rate converts number of requests in window to requests per second
+func (t *Tracker) rate(count uint64) float64 {
+	return float64(count) / t.config.Window.Seconds()
+}
+
+// sketch is a Space-Saving top-k sketch: when it's full, new key replaces the least frequent one
+// inheriting its count, so counts of frequent keys are overestimated at most by err
+type sketch struct {
+	capacity int
+	counters map[string]*counter
+}
+
+type counter struct {
+	count uint64
+	err   uint64
+}
+
+// guaranteed returns lower bound of count, hotness is decided by it: overestimated count of new key
+// in full sketch of many rare keys (e.g. client IPs) would make cold keys hot
+func (c *counter) guaranteed() uint64 {
+	return c.count - c.err
+}
+
+func newSketch(capacity int) *sketch {
+	return &sketch{
+		capacity: capacity,
+		counters: make(map[string]*counter, capacity),
+	}
+}
+
+func (s *sketch) add(key string) {
+	if c, ok := s.counters[key]; ok {
+		c.count++
+		return
+	}
+
+	if len(s.counters) < s.capacity {
+		s.counters[key] = &counter{count: 1}
+		return
+	}
+
+	var minKey string
+	var min *counter
+	for k, c := range s.counters {
+		if min == nil || c.count < min.count {
+			minKey, min = k, c
+		}
+	}
+
+	delete(s.counters, minKey)
+	s.counters[key] = &counter{count: min.count + 1, err: min.count}
+}
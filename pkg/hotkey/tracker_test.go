@@ -0,0 +1,100 @@
+package hotkey
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTrackerHotKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := NewTracker(Config{Capacity: 2, Window: time.Second, Threshold: 10, CoolDownRatio: 0.5})
+	tracker.now = func() time.Time { return now }
+	tracker.windowStart = now
+
+	for i := 0; i < 9; i++ {
+		tracker.Observe("celebrity")
+	}
+	tracker.Observe("regular")
+
+	if tracker.IsHot("celebrity") {
+		t.Fatal("Key must not be hot below threshold")
+	}
+
+	tracker.Observe("celebrity")
+	if !tracker.IsHot("celebrity") {
+		t.Fatal("Key must become hot as soon as threshold is reached")
+	}
+
+	// Hot key stays hot while its rate is above threshold * cool down ratio
+	now = now.Add(time.Second)
+	for i := 0; i < 5; i++ {
+		tracker.Observe("celebrity")
+	}
+
+	now = now.Add(time.Second)
+	if !tracker.IsHot("celebrity") {
+		t.Fatal("Key must stay hot while cooling down")
+	}
+
+	now = now.Add(time.Second)
+	if tracker.IsHot("celebrity") || tracker.IsHot("regular") {
+		t.Fatal("Keys must not be hot without requests")
+	}
+}
+
+func TestTrackerManyColdKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := NewTracker(Config{Capacity: 10, Window: time.Second, Threshold: 50, CoolDownRatio: 0.5})
+	tracker.now = func() time.Time { return now }
+	tracker.windowStart = now
+
+	// Every key is seen twice, but counts inherited in full sketch are far above threshold
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		tracker.Observe(key)
+		tracker.Observe(key)
+		if tracker.IsHot(key) {
+			t.Fatalf("Cold key %s is hot", key)
+		}
+	}
+
+	now = now.Add(time.Second)
+	for _, stat := range tracker.Top() {
+		if stat.Hot {
+			t.Fatalf("Cold key %s is hot after window", stat.Key)
+		}
+	}
+}
+
+func TestTrackerMark(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := NewTracker(Config{Capacity: 2, Window: time.Second, Threshold: 10})
+	tracker.now = func() time.Time { return now }
+	tracker.windowStart = now
+
+	tracker.Mark("remote")
+	if !tracker.IsHot("remote") {
+		t.Fatal("Marked key must be hot")
+	}
+
+	now = now.Add(2 * time.Second)
+	if tracker.IsHot("remote") {
+		t.Fatal("Mark must expire")
+	}
+}
+
+func TestSketchEviction(t *testing.T) {
+	s := newSketch(2)
+	s.add("a")
+	s.add("a")
+	s.add("b")
+	s.add("c")
+
+	if _, ok := s.counters["b"]; ok {
+		t.Fatal("The least frequent key must be evicted")
+	}
+	if c := s.counters["c"]; c.count != 2 || c.err != 1 {
+		t.Fatalf("Unexpected counter: %+v, expected count 2 with error 1", c)
+	}
+}
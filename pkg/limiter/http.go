@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/status"
+)
+
+// Handler returns handler protected by concurrency limiter.
+//
+// Priority of request is taken from priorityHeader as integer, higher value is more important,
+// by default it's 0. Requests that can't be served in time are shed with shedStatus
+// (usually 429 or 503). Gateway errors and responses slower than LatencyThreshold
+// are treated as overload signals that decrease the limit.
+func Handler(l *Limiter, priorityHeader string, shedStatus int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		priority := 0
+		if priorityHeader != "" {
+			priority, _ = strconv.Atoi(r.Header.Get(priorityHeader))
+		}
+
+		release, err := l.Acquire(r.Context(), priority)
+		if err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), shedStatus)
+			return
+		}
+
+		// Slot is released even if handler panics, e.g. when proxy aborts response
+		start := time.Now()
+		recorder := status.NewRecorder(w)
+		defer func() {
+			release(isOverloaded(recorder.Status()) || l.config.LatencyThreshold > 0 && time.Since(start) > l.config.LatencyThreshold)
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+func isOverloaded(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
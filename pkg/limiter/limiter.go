@@ -0,0 +1,268 @@
+package limiter
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+var (
+	// ErrQueueFull is returned when request can't be queued because queue is full of requests with higher priority
+	ErrQueueFull = errors.New("concurrency limit exceeded and queue is full")
+	// ErrQueueTimeout is returned when request waited in queue for too long
+	ErrQueueTimeout = errors.New("concurrency limit exceeded and queue timeout expired")
+)
+
+var (
+	metricConcurrencyLimit  = metrics.MustRegisterGaugeVec("concurrency_limit", "Current adaptive concurrency limit", "limiter")
+	metricInflightRequests  = metrics.MustRegisterGaugeVec("concurrency_inflight_requests", "Number of requests in progress", "limiter")
+	metricQueuedRequests    = metrics.MustRegisterGaugeVec("concurrency_queued_requests", "Number of requests waiting for concurrency limit", "limiter")
+	metricShedRequestsTotal = metrics.MustRegisterCounterVec("concurrency_shed_requests_total", "Total number of requests shed by concurrency limiter", "limiter", "reason")
+)
+
+// Config describes adaptive concurrency limit (AIMD) and its queue
+type Config struct {
+	// InitialLimit is a concurrency limit at start
+	InitialLimit int
+	// MinLimit and MaxLimit bound adaptive concurrency limit
+	MinLimit int
+	MaxLimit int
+	// BackoffRatio is a multiplier applied to limit when request is dropped, e.g. 0.9
+	BackoffRatio float64
+	// LatencyThreshold marks requests slower than given value as dropped, zero disables the rule
+	LatencyThreshold time.Duration
+
+	// MaxQueue is a maximum number of requests waiting for limit
+	MaxQueue int
+	// QueueTimeout is a maximum time request waits in queue
+	QueueTimeout time.Duration
+}
+
+// Limiter is an adaptive concurrency limiter: limit grows by one while requests succeed
+// and the limit is utilized, and it's multiplied by BackoffRatio when request is dropped.
+// Requests exceeding limit wait in priority queue.
+type Limiter struct {
+	name   string
+	config Config
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	queue    waitQueue
+	seq      uint64
+}
+
+// New returns new concurrency limiter, name is used in metrics
+func New(name string, cfg Config) *Limiter {
+	if cfg.MinLimit < 1 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit < cfg.MinLimit {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit > cfg.MaxLimit {
+		cfg.InitialLimit = cfg.MaxLimit
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		cfg.BackoffRatio = 0.9
+	}
+
+	l := &Limiter{
+		name:   name,
+		config: cfg,
+		limit:  float64(cfg.InitialLimit),
+	}
+	l.updateMetrics()
+
+	return l
+}
+
+// Limit returns current concurrency limit
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// Acquire waits until request could be started. Requests with higher priority leave the queue first.
+// When succeeded, returned func must be called once request is done, dropped = true means
+// that request has been failed because of overload (timeout, too slow response, etc.).
+func (l *Limiter) Acquire(ctx context.Context, priority int) (func(dropped bool), error) {
+	l.mu.Lock()
+
+	if l.inflight < int(l.limit) && l.queue.Len() == 0 {
+		l.inflight++
+		l.updateMetrics()
+		l.mu.Unlock()
+
+		return l.releaseFunc(), nil
+	}
+
+	if l.queue.Len() >= l.config.MaxQueue {
+		// Request with the lowest priority gives its place in queue to more important one
+		lowest := l.queue.lowest()
+		if lowest == nil || lowest.priority >= priority {
+			l.mu.Unlock()
+			metricShedRequestsTotal.WithLabelValues(l.name, "queue_full").Inc()
+
+			return nil, ErrQueueFull
+		}
+
+		heap.Remove(&l.queue, lowest.index)
+		lowest.err = ErrQueueFull
+		close(lowest.ready)
+		metricShedRequestsTotal.WithLabelValues(l.name, "queue_full").Inc()
+	}
+
+	l.seq++
+	w := &waiter{
+		priority: priority,
+		seq:      l.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&l.queue, w)
+	l.updateMetrics()
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.config.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+	case <-timer.C:
+		if l.abandon(w) {
+			metricShedRequestsTotal.WithLabelValues(l.name, "queue_timeout").Inc()
+			return nil, ErrQueueTimeout
+		}
+	case <-ctx.Done():
+		if l.abandon(w) {
+			return nil, ctx.Err()
+		}
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return l.releaseFunc(), nil
+}
+
+// abandon removes waiter from queue, returns false if waiter has already left the queue
+func (l *Limiter) abandon(w *waiter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w.index < 0 {
+		return false
+	}
+
+	heap.Remove(&l.queue, w.index)
+	l.updateMetrics()
+
+	return true
+}
+
+func (l *Limiter) releaseFunc() func(dropped bool) {
+	var once sync.Once
+
+	return func(dropped bool) {
+		once.Do(func() {
+			l.release(dropped)
+		})
+	}
+}
+
+func (l *Limiter) release(dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if dropped {
+		l.limit = math.Max(float64(l.config.MinLimit), l.limit*l.config.BackoffRatio)
+	} else if l.inflight*2 >= int(l.limit) {
+		// Limit is increased only when it's utilized, otherwise it grows unbounded on low traffic
+		l.limit = math.Min(float64(l.config.MaxLimit), l.limit+1)
+	}
+
+	l.inflight--
+
+	for l.queue.Len() > 0 && l.inflight < int(l.limit) {
+		w := heap.Pop(&l.queue).(*waiter)
+		l.inflight++
+		close(w.ready)
+	}
+
+	l.updateMetrics()
+}
+
+func (l *Limiter) updateMetrics() {
+	metricConcurrencyLimit.WithLabelValues(l.name).Set(math.Floor(l.limit))
+	metricInflightRequests.WithLabelValues(l.name).Set(float64(l.inflight))
+	metricQueuedRequests.WithLabelValues(l.name).Set(float64(l.queue.Len()))
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	index    int
+	ready    chan struct{}
+	err      error
+}
+
+// waitQueue is a heap of waiters ordered by priority, FIFO within the same priority
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool { return q.less(q[i], q[j]) }
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+
+	return w
+}
+
+// lowest returns the waiter that would leave queue last
+func (q waitQueue) lowest() *waiter {
+	var lowest *waiter
+	for _, w := range q {
+		if lowest == nil || q.less(lowest, w) {
+			lowest = w
+		}
+	}
+
+	return lowest
+}
+
+// less returns true if a leaves queue before b
+func (q waitQueue) less(a, b *waiter) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
@@ -0,0 +1,110 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterAIMD(t *testing.T) {
+	l := New("test-aimd", Config{InitialLimit: 2, MinLimit: 1, MaxLimit: 3, BackoffRatio: 0.5})
+
+	release1, _ := l.Acquire(context.Background(), 0)
+	release2, _ := l.Acquire(context.Background(), 0)
+
+	release1(false)
+	if l.Limit() != 3 {
+		t.Fatalf("Unexpected limit: %d, expected: 3", l.Limit())
+	}
+
+	release2(true)
+	if l.Limit() != 1 {
+		t.Fatalf("Unexpected limit: %d, expected: 1", l.Limit())
+	}
+}
+
+func TestLimiterQueuePriority(t *testing.T) {
+	l := New("test-priority", Config{InitialLimit: 1, MaxLimit: 1, MaxQueue: 2, QueueTimeout: time.Second})
+
+	release, err := l.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	order := make(chan int, 3)
+	errs := make(chan error, 3)
+	acquire := func(priority int) {
+		release, err := l.Acquire(context.Background(), priority)
+		if err != nil {
+			errs <- err
+			return
+		}
+		order <- priority
+		release(false)
+	}
+
+	go acquire(0)
+	waitQueued(t, l, 1)
+	go acquire(1)
+	waitQueued(t, l, 2)
+
+	// Queue is full, request with the lowest priority is shed
+	go acquire(2)
+	if err := <-errs; err != ErrQueueFull {
+		t.Fatalf("Unexpected error: %v, expected: %v", err, ErrQueueFull)
+	}
+
+	release(false)
+
+	if first, second := <-order, <-order; first != 2 || second != 1 {
+		t.Fatalf("Unexpected order: %d, %d, expected: 2, 1", first, second)
+	}
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	l := New("test-timeout", Config{InitialLimit: 1, MaxLimit: 1, MaxQueue: 1, QueueTimeout: 10 * time.Millisecond})
+
+	release, _ := l.Acquire(context.Background(), 0)
+	defer release(false)
+
+	if _, err := l.Acquire(context.Background(), 0); err != ErrQueueTimeout {
+		t.Fatalf("Unexpected error: %v, expected: %v", err, ErrQueueTimeout)
+	}
+}
+
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		l.mu.Lock()
+		queued := l.queue.Len()
+		l.mu.Unlock()
+
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("Requests are not queued, expected: %d", n)
+}
+
+func TestHandlerReleasesAbortedRequest(t *testing.T) {
+	l := New("test-abort", Config{InitialLimit: 1, MinLimit: 1, MaxLimit: 1})
+	h := Handler(l, "", http.StatusServiceUnavailable, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if p := recover(); p != http.ErrAbortHandler {
+					t.Fatalf("Unexpected panic: %v", p)
+				}
+			}()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+}
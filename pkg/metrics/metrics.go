@@ -45,3 +45,65 @@ func MustRegisterCounter(name, help string) prometheus.Counter {
 
 	return collector
 }
+
+// NewGauge creates a new Gauge with predefined namespace
+func NewGauge(name, help string) prometheus.Gauge {
+	return prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: NS,
+			Name:      name,
+			Help:      help,
+		},
+	)
+}
+
+// MustRegisterGauge creates and registers new Gauge with predefined namespace
+// Panics if metrics with same name already registered
+func MustRegisterGauge(name, help string) prometheus.Gauge {
+	collector := NewGauge(name, help)
+	MustRegister(collector)
+
+	return collector
+}
+
+// NewGaugeVec creates a new GaugeVec partitioned by given labels with predefined namespace
+func NewGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: NS,
+			Name:      name,
+			Help:      help,
+		},
+		labels,
+	)
+}
+
+// MustRegisterGaugeVec creates and registers new GaugeVec with predefined namespace
+// Panics if metrics with same name already registered
+func MustRegisterGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	collector := NewGaugeVec(name, help, labels...)
+	MustRegister(collector)
+
+	return collector
+}
+
+// NewCounterVec creates a new CounterVec partitioned by given labels with predefined namespace
+func NewCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: NS,
+			Name:      name,
+			Help:      help,
+		},
+		labels,
+	)
+}
+
+// MustRegisterCounterVec creates and registers new CounterVec with predefined namespace
+// Panics if metrics with same name already registered
+func MustRegisterCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	collector := NewCounterVec(name, help, labels...)
+	MustRegister(collector)
+
+	return collector
+}
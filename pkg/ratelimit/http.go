@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+	"github.com/ozontech/http-ringpop/pkg/route"
+)
+
+const (
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+)
+
+var (
+	metricRateLimitedRequestsTotal = metrics.MustRegisterCounterVec("rate_limited_requests_total", "Total number of requests rejected by rate limiter", "route")
+)
+
+// Handler returns handler that limits rate of requests per ring key according to matching route rule.
+// Rejected requests get 429, all limited requests get standard RateLimit-* headers.
+func Handler(l *Limiter, routes *route.Table, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := routes.Match(r)
+		if rule == nil || rule.RateLimit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := rule.Name + "\x00" + key(r)
+		if rule.RateLimit.ClientHeader != "" {
+			id += "\x00" + r.Header.Get(rule.RateLimit.ClientHeader)
+		}
+
+		result := l.Allow(id, Rate{
+			Limit:  rule.RateLimit.Limit,
+			Period: time.Duration(rule.RateLimit.Period),
+			Burst:  rule.RateLimit.Burst,
+		})
+
+		w.Header().Set(headerRateLimitLimit, strconv.Itoa(result.Limit))
+		w.Header().Set(headerRateLimitRemaining, strconv.Itoa(result.Remaining))
+		w.Header().Set(headerRateLimitReset, seconds(result.Reset))
+
+		if !result.Allowed {
+			metricRateLimitedRequestsTotal.WithLabelValues(rule.Name).Inc()
+
+			w.Header().Set(headerRetryAfter, seconds(result.RetryAfter))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seconds formats duration as whole number of seconds rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
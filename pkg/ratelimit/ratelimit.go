@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is a period of removing idle buckets
+const sweepInterval = time.Minute
+
+// Rate is a token bucket configuration: Limit tokens are added every Period, bucket holds up to Burst tokens
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// capacity returns bucket size, it's Limit if Burst is not set
+func (r Rate) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Limit)
+}
+
+// perSecond returns how many tokens are added per second
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// Result describes rate limiter decision
+type Result struct {
+	Allowed bool
+	// Limit is a bucket size
+	Limit int
+	// Remaining is a number of tokens left in bucket
+	Remaining int
+	// Reset is a time left until bucket is full again
+	Reset time.Duration
+	// RetryAfter is a time left until next request is allowed, it's zero for allowed requests
+	RetryAfter time.Duration
+}
+
+// Limiter is a set of token buckets, safe for concurrent use
+type Limiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	rate    Rate
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter returns new rate limiter
+func NewLimiter() *Limiter {
+	l := &Limiter{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	l.lastSweep = l.now()
+
+	return l
+}
+
+// Allow takes token from bucket with given id
+func (l *Limiter) Allow(id string, rate Rate) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[id]
+	if !ok || b.rate != rate {
+		b = &bucket{rate: rate, tokens: rate.capacity(), updated: now}
+		l.buckets[id] = b
+	}
+	b.refill(now)
+
+	result := Result{Limit: int(rate.capacity())}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = durationOf(1-b.tokens, rate)
+	}
+
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = durationOf(rate.capacity()-b.tokens, rate)
+
+	return result
+}
+
+// sweep removes buckets that are full, they are indistinguishable from new ones
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for id, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.rate.capacity() {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.updated = now
+
+	b.tokens = math.Min(b.rate.capacity(), b.tokens+elapsed*b.rate.perSecond())
+}
+
+// durationOf returns time required to get given number of tokens
+func durationOf(tokens float64, rate Rate) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / rate.perSecond() * float64(time.Second))
+}
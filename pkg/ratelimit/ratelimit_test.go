@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+
+	rate := Rate{Limit: 2, Period: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if result := l.Allow("key", rate); !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("Request %d: unexpected result: %+v", i, result)
+		}
+	}
+
+	result := l.Allow("key", rate)
+	if result.Allowed {
+		t.Fatal("Request must be rejected when bucket is empty")
+	}
+	if result.RetryAfter != 500*time.Millisecond || result.Reset != 1500*time.Millisecond {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	// Other keys have their own buckets
+	if result := l.Allow("another", rate); !result.Allowed {
+		t.Fatal("Request with another key must be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result := l.Allow("key", rate); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("Unexpected result after refill: %+v", result)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	l.Allow("key", Rate{Limit: 1, Period: time.Second})
+
+	now = now.Add(sweepInterval)
+	l.Allow("another", Rate{Limit: 1, Period: time.Second})
+
+	if _, ok := l.buckets["key"]; ok {
+		t.Fatal("Full bucket must be removed")
+	}
+}
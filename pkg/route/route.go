@@ -0,0 +1,170 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Rule describes settings of requests matching path prefix and methods
+type Rule struct {
+	// Name identifies rule in metrics and logs
+	Name string `json:"name"`
+	// PathPrefix of request URL path, empty prefix matches any path
+	PathPrefix string `json:"path_prefix"`
+	// Methods of request, empty list matches any method
+	Methods []string `json:"methods,omitempty"`
+
+	// RateLimit is applied by key owner before request is passed to backend
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// ReadReplicas allows to serve GET and HEAD requests by successors of key owner,
+	// it makes sense only if backends share the data
+	ReadReplicas bool `json:"read_replicas,omitempty"`
+	// Coalesce collapses concurrent identical GET and HEAD requests into one backend call
+	Coalesce *Coalesce `json:"coalesce,omitempty"`
+}
+
+// RateLimit is a token bucket: Limit requests per Period with bursts up to Burst requests
+type RateLimit struct {
+	Limit  int      `json:"limit"`
+	Period Duration `json:"period"`
+	Burst  int      `json:"burst,omitempty"`
+	// ClientHeader splits bucket of ring key by value of given header, e.g. API key
+	ClientHeader string `json:"client_header,omitempty"`
+}
+
+// Coalesce describes which requests are identical: they must have the same method, URL, credentials and Vary headers
+type Coalesce struct {
+	// Vary lists request headers that make response different, e.g. Accept-Encoding
+	Vary []string `json:"vary,omitempty"`
+}
+
+// Matches returns true if request matches rule
+func (r *Rule) Matches(req *http.Request) bool {
+	if !strings.HasPrefix(req.URL.Path, r.PathPrefix) {
+		return false
+	}
+
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	for _, method := range r.Methods {
+		if strings.EqualFold(method, req.Method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsReplicatedRead returns true if request could be served by successors of key owner
+func (r *Rule) IsReplicatedRead(req *http.Request) bool {
+	if r == nil || !r.ReadReplicas {
+		return false
+	}
+
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is empty")
+	}
+
+	if rl := r.RateLimit; rl != nil {
+		if rl.Limit <= 0 || rl.Period <= 0 {
+			return fmt.Errorf("rule %s: rate limit and period must be positive", r.Name)
+		}
+		if rl.Burst < 0 {
+			return fmt.Errorf("rule %s: rate limit burst must not be negative", r.Name)
+		}
+	}
+
+	return nil
+}
+
+// Table is a set of rules, request is handled according to the rule with the longest matching prefix
+type Table struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Load reads table of rules from JSON file
+//
+// JSON file example:
+// {"rules": [{"name": "api", "path_prefix": "/api/", "rate_limit": {"limit": 10, "period": "1s"}}]}
+func Load(filePath string) (*Table, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("unable to parse routes file %s: %v", filePath, err)
+	}
+
+	names := make(map[string]bool, len(table.Rules))
+	for i, rule := range table.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("rule #%d is null", i+1)
+		}
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return table, nil
+}
+
+// Match returns rule for given request or nil if there is no matching rule
+func (t *Table) Match(req *http.Request) *Rule {
+	if t == nil {
+		return nil
+	}
+
+	var matched *Rule
+	for _, rule := range t.Rules {
+		if !rule.Matches(req) {
+			continue
+		}
+		if matched == nil || len(rule.PathPrefix) > len(matched.PathPrefix) {
+			matched = rule
+		}
+	}
+
+	return matched
+}
+
+// Duration is a time.Duration that is represented in JSON as a string, e.g. "1.5s"
+type Duration time.Duration
+
+// UnmarshalJSON parses duration from string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+
+	return nil
+}
+
+// MarshalJSON formats duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
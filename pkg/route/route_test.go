@@ -0,0 +1,55 @@
+package route
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestTableMatch(t *testing.T) {
+	table := &Table{Rules: []*Rule{
+		{Name: "root", PathPrefix: "/"},
+		{Name: "api-read", PathPrefix: "/api/", Methods: []string{"GET", "HEAD"}},
+		{Name: "api-users", PathPrefix: "/api/users/"},
+	}}
+
+	tests := []struct {
+		method, url, rule string
+	}{
+		{"GET", "http://localhost/", "root"},
+		{"GET", "http://localhost/api/items", "api-read"},
+		{"POST", "http://localhost/api/items", "root"},
+		{"POST", "http://localhost/api/users/1", "api-users"},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.url, nil)
+		if rule := table.Match(r); rule == nil || rule.Name != test.rule {
+			t.Fatalf("Unexpected rule for %s %s: %v, expected: %s", test.method, test.url, rule, test.rule)
+		}
+	}
+
+	var empty *Table
+	r, _ := http.NewRequest("GET", "http://localhost/", nil)
+	if rule := empty.Match(r); rule != nil {
+		t.Fatalf("Unexpected rule: %v", rule)
+	}
+}
+
+func TestLoadInvalidRules(t *testing.T) {
+	for _, content := range []string{
+		`{"rules": [null]}`,
+		`{"rules": [{"path_prefix": "/api/"}]}`,
+		`{"rules": [{"name": "api"}, {"name": "api"}]}`,
+	} {
+		path := filepath.Join(t.TempDir(), "routes.json")
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to write routes file: %v", err)
+		}
+
+		if _, err := Load(path); err == nil {
+			t.Fatalf("Error is expected for %s", content)
+		}
+	}
+}
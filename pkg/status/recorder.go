@@ -0,0 +1,31 @@
+package status
+
+import (
+	"net/http"
+)
+
+// Recorder remembers status code written to underlying ResponseWriter
+type Recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// NewRecorder returns recorder of response written to w, status is 200 until it's written
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns written status code
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns underlying ResponseWriter, it's used by http.ResponseController
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
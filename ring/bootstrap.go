@@ -0,0 +1,237 @@
+package ring
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/discovery"
+	"github.com/uber/ringpop-go/swim"
+)
+
+// defaultRefreshJoinDuration limits join of discovered hosts, it's shorter than ringpop's default
+// because discovered host could be dead already
+const defaultRefreshJoinDuration = 10 * time.Second
+
+var (
+	metricBootstrapFailuresTotal = metrics.MustRegisterCounter("bootstrap_failures_total", "Total number of failed attempts to bootstrap ringpop")
+	metricRejoinsTotal           = metrics.MustRegisterCounter("rejoins_total", "Total number of attempts to join discovered hosts which aren't members of the ring")
+	metricDiscoveredHosts        = metrics.MustRegisterGauge("discovered_hosts", "Number of hosts returned by discovery provider on the last refresh")
+)
+
+// BootstrapConfig describes how ringpop joins the ring
+type BootstrapConfig struct {
+	// Attempts is a maximum number of bootstrap attempts, 0 means attempts aren't limited
+	Attempts int
+	// InitialBackoff is a delay after the first failed attempt, it's doubled after every next one
+	InitialBackoff time.Duration
+	// MaxBackoff is an upper bound of delay between attempts
+	MaxBackoff time.Duration
+	// MaxJoinDuration limits a single attempt, 0 means ringpop's default (2 minutes)
+	MaxJoinDuration time.Duration
+	// SingleNode makes node start as single-node ring when all attempts failed
+	SingleNode bool
+}
+
+// BootstrapRingpopWithRetry bootstraps ringpop retrying failed attempts with exponential backoff.
+// When all attempts failed node starts as single-node ring if it's allowed by config,
+// otherwise the last error is returned.
+func BootstrapRingpopWithRetry(ctx context.Context, rp *ringpop.Ringpop, provider discovery.DiscoverProvider, cfg BootstrapConfig, logger bark.Logger) error {
+	opts := &swim.BootstrapOptions{
+		DiscoverProvider: provider,
+		MaxJoinDuration:  cfg.MaxJoinDuration,
+	}
+
+	backoff := cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := rp.Bootstrap(opts)
+		if err == nil {
+			return nil
+		}
+		metricBootstrapFailuresTotal.Inc()
+
+		if cfg.Attempts > 0 && attempt >= cfg.Attempts {
+			if !cfg.SingleNode {
+				return err
+			}
+
+			logger.Warnf("Unable to bootstrap ringpop after %d attempts, starting as single-node ring: %v", attempt, err)
+			return bootstrapSingleNode(rp, provider)
+		}
+
+		logger.Warnf("Unable to bootstrap ringpop (attempt %d), retrying in %v: %v", attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+// bootstrapSingleNode bootstraps ringpop without contacting other hosts, ringpop adds itself
+// to empty list of hosts. Node which has been bootstrapped before keeps its membership.
+func bootstrapSingleNode(rp *ringpop.Ringpop, provider discovery.DiscoverProvider) error {
+	_, err := rp.Bootstrap(&swim.BootstrapOptions{DiscoverProvider: &joinProvider{provider: provider}})
+	return err
+}
+
+// JoinDiscoveredHosts periodically queries discovery provider and joins hosts which aren't reachable
+// members of the ring, e.g. new nodes of scale-up.
+// Node started as single-node ring joins the others the same way.
+// Providers with Changed() <-chan struct{} method are queried right after change too.
+// Hosts which are known to membership as faulty (or left) members are skipped: failed join makes ringpop
+// not ready for a moment, and partitions with them are healed by ringpop's healer anyway.
+// Membership may be nil, then only reachable members are skipped.
+// Discovered labels of this node are shared, see ApplyDiscoveredLabels.
+// It blocks until context is done.
+func JoinDiscoveredHosts(ctx context.Context, rp *ringpop.Ringpop, members *Membership, provider discovery.DiscoverProvider, interval, maxJoinDuration time.Duration, logger bark.Logger) {
+	if maxJoinDuration <= 0 {
+		maxJoinDuration = defaultRefreshJoinDuration
+	}
+
+	// Nil channel of provider without change notifications never fires
+	var changed <-chan struct{}
+	if watcher, ok := provider.(interface{ Changed() <-chan struct{} }); ok {
+		changed = watcher.Changed()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var labels map[string]string
+	applyLabels := func() {
+		var err error
+		if labels, err = ApplyDiscoveredLabels(rp, provider, labels); err != nil {
+			logger.Warnf("Unable to share discovered labels: %v", err)
+		}
+	}
+	applyLabels()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+		}
+
+		applyLabels()
+
+		hosts, err := unknownHosts(rp, members, provider)
+		if err != nil {
+			logger.Warnf("Unable to discover hosts: %v", err)
+			continue
+		}
+		if len(hosts) == 0 {
+			continue
+		}
+
+		logger.Infof("Joining discovered hosts %s...", strings.Join(hosts, ", "))
+		metricRejoinsTotal.Inc()
+
+		if err := join(rp, provider, hosts, maxJoinDuration); err != nil {
+			logger.Errorf("Unable to join discovered hosts: %v", err)
+			continue
+		}
+
+		logger.Info("...OK")
+	}
+}
+
+// RejoinWhenAlone makes node left alone in the ring rejoin the others.
+//
+// Deprecated: use JoinDiscoveredHosts, it rejoins lonely node and joins any other discovered hosts
+// which aren't members of the ring.
+func RejoinWhenAlone(ctx context.Context, rp *ringpop.Ringpop, provider discovery.DiscoverProvider, interval, maxJoinDuration time.Duration, logger bark.Logger) {
+	JoinDiscoveredHosts(ctx, rp, nil, provider, interval, maxJoinDuration, logger)
+}
+
+// join contacts given hosts, the rest of their ring is learned from membership of the first one joined
+func join(rp *ringpop.Ringpop, provider discovery.DiscoverProvider, hosts []string, maxJoinDuration time.Duration) error {
+	_, err := rp.Bootstrap(&swim.BootstrapOptions{
+		DiscoverProvider: &joinProvider{hosts: hosts, provider: provider},
+		JoinSize:         1,
+		MaxJoinDuration:  maxJoinDuration,
+	})
+	if err == nil {
+		return nil
+	}
+
+	// Failed bootstrap leaves ringpop not ready, bootstrap without hosts makes it ready again
+	// and leaves membership as it is
+	if restoreErr := bootstrapSingleNode(rp, provider); restoreErr != nil {
+		return restoreErr
+	}
+
+	return err
+}
+
+// joinProvider returns given hosts to the join of bootstrap only, later calls are made by partition healer
+// of ringpop and go to the real provider
+type joinProvider struct {
+	hosts    []string
+	provider discovery.DiscoverProvider
+	joined   int32
+}
+
+func (p *joinProvider) Hosts() ([]string, error) {
+	if atomic.CompareAndSwapInt32(&p.joined, 0, 1) {
+		return p.hosts, nil
+	}
+
+	return p.provider.Hosts()
+}
+
+// unknownHosts returns discovered hosts which aren't members of the ring
+func unknownHosts(rp *ringpop.Ringpop, members *Membership, provider discovery.DiscoverProvider) ([]string, error) {
+	if !rp.Ready() {
+		return nil, nil
+	}
+
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return nil, err
+	}
+
+	reachable, err := rp.GetReachableMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(reachable)+1)
+	known[self] = true
+	for _, member := range reachable {
+		known[member] = true
+	}
+	if members != nil {
+		for _, member := range members.Members() {
+			known[member.Address] = true
+		}
+	}
+
+	hosts, err := provider.Hosts()
+	if err != nil {
+		return nil, err
+	}
+	metricDiscoveredHosts.Set(float64(len(hosts)))
+
+	var unknown []string
+	for _, host := range hosts {
+		if !known[host] {
+			unknown = append(unknown, host)
+			known[host] = true
+		}
+	}
+
+	return unknown, nil
+}
@@ -0,0 +1,113 @@
+package ring
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go/discovery/statichosts"
+	"github.com/uber/ringpop-go/swim"
+)
+
+// flakyProvider fails given number of times before it returns hosts
+type flakyProvider struct {
+	failures int32
+	hosts    []string
+}
+
+func (p *flakyProvider) Hosts() ([]string, error) {
+	if atomic.AddInt32(&p.failures, -1) >= 0 {
+		return nil, errors.New("no hosts yet")
+	}
+
+	return p.hosts, nil
+}
+
+func TestBootstrapRingpopWithRetry(t *testing.T) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+	cfg := BootstrapConfig{InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	rp, address := newUnbootstrappedRingpop(t)
+	if err := BootstrapRingpopWithRetry(context.Background(), rp, &flakyProvider{failures: 2, hosts: []string{address}}, cfg, logger); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !rp.Ready() {
+		t.Fatal("Ringpop must be ready after bootstrap")
+	}
+
+	cfg.Attempts = 2
+	rp, address = newUnbootstrappedRingpop(t)
+	if err := BootstrapRingpopWithRetry(context.Background(), rp, &flakyProvider{failures: 2, hosts: []string{address}}, cfg, logger); err == nil {
+		t.Fatal("Expected error when attempts are exhausted")
+	}
+
+	cfg.SingleNode = true
+	if err := BootstrapRingpopWithRetry(context.Background(), rp, &flakyProvider{failures: 2, hosts: []string{address}}, cfg, logger); err != nil {
+		t.Fatalf("Unexpected error of single-node ring: %v", err)
+	}
+	if count, err := rp.CountReachableMembers(); err != nil || count != 1 {
+		t.Fatalf("Unexpected members of single-node ring: %d, %v", count, err)
+	}
+}
+
+func TestJoinProvider(t *testing.T) {
+	// Single-node ring keeps the real provider, partition healer of ringpop uses it later
+	provider := &joinProvider{provider: statichosts.New("127.0.0.1:3001", "127.0.0.1:3002")}
+	if hosts, err := provider.Hosts(); err != nil || len(hosts) != 0 {
+		t.Fatalf("Unexpected hosts of join: %v, %v", hosts, err)
+	}
+	if hosts, err := provider.Hosts(); err != nil || len(hosts) != 2 {
+		t.Fatalf("Unexpected hosts after join: %v, %v", hosts, err)
+	}
+}
+
+func TestJoinDiscoveredHosts(t *testing.T) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+
+	first, firstAddress := newUnbootstrappedRingpop(t)
+	second, secondAddress := newUnbootstrappedRingpop(t)
+	members := NewMembership(second)
+	if err := BootstrapRingpop(first, statichosts.New(firstAddress)); err != nil {
+		t.Fatalf("Unable to bootstrap ringpop: %v", err)
+	}
+	if err := BootstrapRingpop(second, statichosts.New(secondAddress)); err != nil {
+		t.Fatalf("Unable to bootstrap ringpop: %v", err)
+	}
+
+	provider := statichosts.New(firstAddress, secondAddress)
+	if hosts, err := unknownHosts(second, members, provider); err != nil || len(hosts) != 1 || hosts[0] != firstAddress {
+		t.Fatalf("Unexpected unknown hosts: %v, %v", hosts, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go JoinDiscoveredHosts(ctx, second, members, provider, 10*time.Millisecond, time.Second, logger)
+
+	for i := 0; ; i++ {
+		firstCount, _ := first.CountReachableMembers()
+		secondCount, _ := second.CountReachableMembers()
+		if firstCount == 2 && secondCount == 2 {
+			break
+		}
+		if i == 300 {
+			t.Fatalf("Nodes haven't joined each other: %d, %d members", firstCount, secondCount)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if hosts, err := unknownHosts(second, members, provider); err != nil || len(hosts) != 0 {
+		t.Fatalf("Unexpected unknown hosts after join: %v, %v", hosts, err)
+	}
+
+	// Faulty member is left to partition healer of ringpop, failed join would make node not ready
+	faultyAddress := "127.0.0.1:1"
+	members.update([]Member{{Address: faultyAddress, Status: swim.Faulty, Incarnation: 1}})
+	provider = statichosts.New(firstAddress, secondAddress, faultyAddress)
+	if hosts, err := unknownHosts(second, members, provider); err != nil || len(hosts) != 0 {
+		t.Fatalf("Faulty member must not be joined: %v, %v", hosts, err)
+	}
+}
@@ -0,0 +1,115 @@
+package ring
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/uber/ringpop-go"
+)
+
+// maxLookupSuccessors limits number of successors returned by LookupHandler
+const maxLookupSuccessors = 100
+
+// SelectorHandler returns handler that shows members of selector's lookup table in JSON
+func SelectorHandler(s *Selector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, struct {
+			Algorithm string           `json:"algorithm"`
+			Members   []WeightedMember `json:"members"`
+		}{
+			Algorithm: s.Algorithm(),
+			Members:   s.Members(),
+		})
+	})
+}
+
+// MembersHandler returns handler that shows all known members with their statuses in JSON
+func MembersHandler(rp *ringpop.Ringpop, m *Membership) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Address is unknown until ringpop is bootstrapped
+		address, _ := rp.WhoAmI()
+
+		type member struct {
+			Member
+			Self bool `json:"self,omitempty"`
+		}
+
+		members := []member{}
+		for _, mbr := range m.Members() {
+			members = append(members, member{Member: mbr, Self: mbr.Address == address})
+		}
+
+		writeJSON(w, members)
+	})
+}
+
+// WhoAmIHandler returns handler that shows address of this node and whether it's ready in JSON
+func WhoAmIHandler(rp *ringpop.Ringpop) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		address, err := rp.WhoAmI()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, struct {
+			Address string `json:"address"`
+			Ready   bool   `json:"ready"`
+		}{address, rp.Ready()})
+	})
+}
+
+// ChecksumHandler returns handler that shows checksum of membership in JSON,
+// nodes with the same checksum have the same view of the ring
+func ChecksumHandler(rp *ringpop.Ringpop) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checksum, err := rp.Checksum()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, struct {
+			Checksum uint32 `json:"checksum"`
+		}{checksum})
+	})
+}
+
+// LookupHandler returns handler that shows owner of key and its n successors in JSON,
+// e.g. /debug/lookup?key=127.0.0.1&n=2
+func LookupHandler(rp *ringpop.Ringpop, options ...ResolveOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		n := 2
+		if value := r.URL.Query().Get("n"); value != "" {
+			var err error
+			if n, err = strconv.Atoi(value); err != nil || n < 0 || n > maxLookupSuccessors {
+				http.Error(w, "n parameter must be a number from 0 to "+strconv.Itoa(maxLookupSuccessors), http.StatusBadRequest)
+				return
+			}
+		}
+
+		nodes, err := ResolveDestinationNodes(rp, key, n+1, options...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, struct {
+			Key        string   `json:"key"`
+			Owner      string   `json:"owner"`
+			Successors []string `json:"successors"`
+		}{key, nodes[0], nodes[1:]})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
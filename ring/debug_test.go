@@ -0,0 +1,139 @@
+package ring
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/discovery/statichosts"
+)
+
+// newTestRingpop returns bootstrapped single node ring
+func newTestRingpop(t *testing.T) (*ringpop.Ringpop, *Membership) {
+	rp, address := newUnbootstrappedRingpop(t)
+
+	members := NewMembership(rp)
+	if err := BootstrapRingpop(rp, statichosts.New(address)); err != nil {
+		t.Fatalf("Unable to bootstrap ringpop: %v", err)
+	}
+
+	// Ringpop emits events asynchronously
+	for i := 0; len(members.Members()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("Local member is not seen by membership")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return rp, members
+}
+
+// newUnbootstrappedRingpop returns ringpop listening on random port and its address
+func newUnbootstrappedRingpop(t *testing.T) (*ringpop.Ringpop, string) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+
+	ch := newTestChannel(t)
+	if err := ch.ListenAndServe("127.0.0.1:0"); err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	address := ch.PeerInfo().HostPort
+
+	rp, err := ringpop.New(appName, ringpop.Channel(ch), ringpop.Address(address), ringpop.Logger(logger))
+	if err != nil {
+		t.Fatalf("Unable to create ringpop: %v", err)
+	}
+	t.Cleanup(rp.Destroy)
+
+	return rp, address
+}
+
+func getJSON(t *testing.T, h http.Handler, url string, v interface{}) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+			t.Fatalf("Unable to decode response %s: %v", w.Body.String(), err)
+		}
+	}
+
+	return w.Code
+}
+
+func TestDebugHandlers(t *testing.T) {
+	rp, members := newTestRingpop(t)
+	address, _ := rp.WhoAmI()
+
+	var lookup struct {
+		Key        string   `json:"key"`
+		Owner      string   `json:"owner"`
+		Successors []string `json:"successors"`
+	}
+	if code := getJSON(t, LookupHandler(rp), "/debug/lookup?key=abc&n=2", &lookup); code != http.StatusOK {
+		t.Fatalf("Unexpected status: %d", code)
+	}
+	if lookup.Key != "abc" || lookup.Owner != address || len(lookup.Successors) != 0 {
+		t.Fatalf("Unexpected lookup: %+v", lookup)
+	}
+
+	if code := getJSON(t, LookupHandler(rp), "/debug/lookup", &lookup); code != http.StatusBadRequest {
+		t.Fatalf("Unexpected status without key: %d", code)
+	}
+
+	var whoami struct {
+		Address string `json:"address"`
+		Ready   bool   `json:"ready"`
+	}
+	getJSON(t, WhoAmIHandler(rp), "/debug/whoami", &whoami)
+	if whoami.Address != address || !whoami.Ready {
+		t.Fatalf("Unexpected identity: %+v", whoami)
+	}
+
+	var list []struct {
+		Address string `json:"address"`
+		Status  string `json:"status"`
+		Self    bool   `json:"self"`
+	}
+	getJSON(t, MembersHandler(rp, members), "/debug/members", &list)
+	if len(list) != 1 || list[0].Address != address || list[0].Status != "alive" || !list[0].Self {
+		t.Fatalf("Unexpected members: %+v", list)
+	}
+
+	var checksum struct {
+		Checksum uint32 `json:"checksum"`
+	}
+	getJSON(t, ChecksumHandler(rp), "/debug/checksum", &checksum)
+	if expected, _ := rp.Checksum(); checksum.Checksum != expected {
+		t.Fatalf("Unexpected checksum: %d, expected: %d", checksum.Checksum, expected)
+	}
+}
+
+func TestHealthChecks(t *testing.T) {
+	rp, members := newTestRingpop(t)
+	ch := newTestChannel(t)
+	if err := ch.ListenAndServe("127.0.0.1:0"); err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := ReadyCheck(rp)(ctx); err != nil {
+		t.Fatalf("Bootstrapped ringpop must be ready: %v", err)
+	}
+	if err := MembersCheck(members, 1)(ctx); err != nil {
+		t.Fatalf("Unexpected error of members check: %v", err)
+	}
+	if err := MembersCheck(members, 2)(ctx); err == nil {
+		t.Fatal("Single node ring must not pass check of 2 members")
+	}
+	if err := LiveCheck(rp, ch)(ctx); err != nil {
+		t.Fatalf("Unexpected error of live check: %v", err)
+	}
+}
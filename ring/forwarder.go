@@ -1,22 +1,38 @@
 package ring
 
 import (
+	"context"
+	"time"
+
+	"github.com/ozontech/http-ringpop/pkg/breaker"
+
 	"github.com/uber-common/bark"
 	"github.com/uber/ringpop-go"
 	"github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/raw"
 )
 
+// forwardTimeout is a timeout of forwarded request, it's the same as ringpop's default one
+const forwardTimeout = 3 * time.Second
+
 // Forwarder is a request forwarder used to transfer request between nodes in hashring
 type Forwarder interface {
 	Forward(node, key string, request []byte) ([]byte, error)
 }
 
+// ContextForwarder is a forwarder which request could be canceled
+type ContextForwarder interface {
+	Forwarder
+	ForwardContext(ctx context.Context, node, key string, request []byte) ([]byte, error)
+}
+
 // NewForwarder returns new request forwarder
-func NewForwarder(rp *ringpop.Ringpop, l bark.Logger) *RequestForwarder {
+func NewForwarder(rp *ringpop.Ringpop, ch *tchannel.Channel, l bark.Logger) *RequestForwarder {
 	return &RequestForwarder{
 		channelName: channelName,
 		endpoint:    endpoint,
 		ringpop:     rp,
+		channel:     ch,
 		logger:      l,
 	}
 }
@@ -27,6 +43,7 @@ type RequestForwarder struct {
 	endpoint    string
 
 	ringpop *ringpop.Ringpop
+	channel *tchannel.Channel
 	logger  bark.Logger
 }
 
@@ -37,3 +54,70 @@ func (f *RequestForwarder) Forward(node, key string, request []byte) ([]byte, er
 	)
 	return f.ringpop.Forward(node, []string{key}, request, f.channelName, f.endpoint, tchannel.HTTP, nil)
 }
+
+// ForwardContext sends request to node directly over TChannel, so it's canceled with ctx.
+// Unlike Forward, request is not rerouted when node stops being key owner.
+func (f *RequestForwarder) ForwardContext(ctx context.Context, node, key string, request []byte) ([]byte, error) {
+	f.logger.Infof(
+		"Forwarding request with context to node: %s, key: %s, channel: %s, endpoint: %s",
+		node, key, f.channelName, f.endpoint,
+	)
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, forwardTimeout)
+		defer cancel()
+	}
+
+	call, err := f.channel.BeginCall(ctx, node, f.channelName, f.endpoint, &tchannel.CallOptions{Format: tchannel.HTTP})
+	if err != nil {
+		return nil, err
+	}
+
+	_, response, _, err := raw.WriteArgs(call, nil, request)
+	return response, err
+}
+
+// NewCircuitBreakingForwarder wraps forwarder with circuit breaker per destination node.
+// Requests to node with open circuit breaker fail immediately with breaker.ErrOpen.
+func NewCircuitBreakingForwarder(f Forwarder, breakers *breaker.Group) Forwarder {
+	return &circuitBreakingForwarder{
+		forwarder: f,
+		breakers:  breakers,
+	}
+}
+
+type circuitBreakingForwarder struct {
+	forwarder Forwarder
+	breakers  *breaker.Group
+}
+
+func (f *circuitBreakingForwarder) Forward(node, key string, request []byte) ([]byte, error) {
+	done, err := f.breakers.Get(node).Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := f.forwarder.Forward(node, key, request)
+	done(err == nil)
+
+	return response, err
+}
+
+func (f *circuitBreakingForwarder) ForwardContext(ctx context.Context, node, key string, request []byte) ([]byte, error) {
+	cf, ok := f.forwarder.(ContextForwarder)
+	if !ok {
+		return f.Forward(node, key, request)
+	}
+
+	done, err := f.breakers.Get(node).Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := cf.ForwardContext(ctx, node, key, request)
+	// Canceled request says nothing about node health
+	done(err == nil || ctx.Err() != nil)
+
+	return response, err
+}
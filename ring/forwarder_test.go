@@ -0,0 +1,63 @@
+package ring
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/tchannel-go"
+)
+
+func newTestChannel(t *testing.T) *tchannel.Channel {
+	ch, err := NewChannel()
+	if err != nil {
+		t.Fatalf("Unable to create channel: %v", err)
+	}
+	t.Cleanup(ch.Close)
+
+	return ch
+}
+
+func TestForwardContext(t *testing.T) {
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Second)
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "response to ", r.URL.Path)
+	})
+
+	server := NewServer(newTestChannel(t), backend, logger)
+	if err := server.ListenAndServe("127.0.0.1:0"); err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	node := server.channel.PeerInfo().HostPort
+
+	f := NewForwarder(nil, newTestChannel(t), logger)
+
+	response, err := f.ForwardContext(context.Background(), node, "key", []byte("GET /fast HTTP/1.1\r\nHost: node\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(string(response), "response to /fast") {
+		t.Fatalf("Unexpected response: %s", response)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := f.ForwardContext(ctx, node, "key", []byte("GET /slow HTTP/1.1\r\nHost: node\r\n\r\n")); err == nil {
+		t.Fatal("Expected error of canceled request")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Canceled request took %v", elapsed)
+	}
+}
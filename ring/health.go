@@ -0,0 +1,54 @@
+package ring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/uber/ringpop-go"
+	"github.com/uber/tchannel-go"
+)
+
+// ErrNotReady is returned by ReadyCheck until ringpop is bootstrapped
+var ErrNotReady = errors.New("ringpop is not bootstrapped")
+
+// ReadyCheck returns check that fails until ringpop is bootstrapped
+func ReadyCheck(rp *ringpop.Ringpop) func(context.Context) error {
+	return func(context.Context) error {
+		if !rp.Ready() {
+			return ErrNotReady
+		}
+
+		return nil
+	}
+}
+
+// MembersCheck returns check that fails while less than min reachable members (including this node) are known
+func MembersCheck(m *Membership, min int) func(context.Context) error {
+	return func(context.Context) error {
+		if n := len(m.ReachableMembers()); n < min {
+			return fmt.Errorf("%d reachable members of %d required", n, min)
+		}
+
+		return nil
+	}
+}
+
+// LiveCheck returns check that fails when ringpop or TChannel stop responding:
+// ringpop must hand out its checksum and TChannel must answer ping of this node.
+// Check passes before bootstrap, node isn't live-locked just because peers are unknown yet.
+func LiveCheck(rp *ringpop.Ringpop, ch *tchannel.Channel) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if rp.Ready() {
+			if _, err := rp.Checksum(); err != nil {
+				return err
+			}
+		}
+
+		if ch.State() != tchannel.ChannelListening {
+			return nil
+		}
+
+		return ch.Ping(ctx, ch.PeerInfo().HostPort)
+	}
+}
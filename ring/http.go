@@ -7,8 +7,15 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+
+	"github.com/uber/ringpop-go"
 )
 
+// HeaderKey is a header with ring key of request, it's set by the node that received request,
+// so key owner doesn't need to extract key again. It's removed before request reaches backend,
+// see StripKeyHeader.
+const HeaderKey = "X-Ringpop-Key"
+
 // HTTPResponseWriter is a simple http.ResponseWriter implementation
 type HTTPResponseWriter struct {
 	headers http.Header
@@ -38,12 +45,12 @@ func (r *HTTPResponseWriter) WriteHeader(status int) {
 
 func (r *HTTPResponseWriter) Response() *http.Response {
 	resp := &http.Response{
-		Header:        r.headers,
-		Status:        http.StatusText(r.status),
-		StatusCode:    r.status,
-		ProtoMajor:    1,
-		ProtoMinor:    1,
-		Body:          ioutil.NopCloser(bytes.NewReader(r.body)),
+		Header:     r.headers,
+		Status:     http.StatusText(r.status),
+		StatusCode: r.status,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Body:       ioutil.NopCloser(bytes.NewReader(r.body)),
 	}
 
 	// Propagate Content-Length header
@@ -56,10 +63,24 @@ func (r *HTTPResponseWriter) Response() *http.Response {
 	return resp
 }
 
+// Sources of ring key extracted from request
+const (
+	KeySourceXForwardedFor = "X-Forwarded-For"
+	KeySourceXRealIP       = "X-Real-Ip"
+	KeySourceRemoteAddr    = "RemoteAddr"
+)
+
 // RequestToKey converts request to key, that will be used for hash ring
 // This func uses client IP as key
 func RequestToKey(r *http.Request) string {
-	for _, h := range []string{"X-Forwarded-For", "X-Real-Ip"} {
+	key, _ := ExtractKey(r)
+	return key
+}
+
+// ExtractKey returns ring key of request and where it was taken from: the first public address
+// from the right in X-Forwarded-For or X-Real-Ip headers, otherwise remote address of connection
+func ExtractKey(r *http.Request) (key, source string) {
+	for _, h := range []string{KeySourceXForwardedFor, KeySourceXRealIP} {
 		addresses := strings.Split(r.Header.Get(h), ",")
 		// march from right to left until we get a public address
 		// that will be the address right before our proxy.
@@ -71,9 +92,49 @@ func RequestToKey(r *http.Request) string {
 				// bad address, go to next
 				continue
 			}
-			return ip
+			return ip, h
 		}
 	}
 
-	return r.RemoteAddr
+	return r.RemoteAddr, KeySourceRemoteAddr
+}
+
+// RequestKey returns ring key of request that was set by the node that received request
+func RequestKey(r *http.Request) string {
+	return r.Header.Get(HeaderKey)
+}
+
+// StripKeyHeader removes internal header with ring key from requests passed to next handler
+func StripKeyHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderKey) != "" {
+			// Request is shared with outer handlers, so its header is copied
+			r = r.WithContext(r.Context())
+			r.Header = r.Header.Clone()
+			r.Header.Del(HeaderKey)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OwnerHandler passes requests of keys owned by this node to owned handler and the rest to other handler.
+// Owner is taken from the ring as it is, so requests served by other nodes (hot keys spread among successors,
+// owner with unhealthy backend or overloaded owner) go to other handler, e.g. they aren't cached.
+func OwnerHandler(rp *ringpop.Ringpop, owned, other http.Handler, options ...ResolveOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		self, err := rp.WhoAmI()
+		if err != nil {
+			other.ServeHTTP(w, r)
+			return
+		}
+
+		nodes, err := LookupNodes(rp, RequestKey(r), 1, options...)
+		if err != nil || nodes[0] != self {
+			other.ServeHTTP(w, r)
+			return
+		}
+
+		owned.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,49 @@
+package ring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripKeyHeader(t *testing.T) {
+	h := StripKeyHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderKey) != "" {
+			t.Fatal("Ring key is passed to backend")
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderKey, "10.0.0.1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if RequestKey(r) != "10.0.0.1" {
+		t.Fatal("Ring key is removed from request of outer handlers")
+	}
+}
+
+// fixedSelector makes all keys owned by given node
+type fixedSelector string
+
+func (s fixedSelector) LookupN(key string, n int) ([]string, error) {
+	return []string{string(s)}, nil
+}
+
+func TestOwnerHandler(t *testing.T) {
+	rp, _ := newTestRingpop(t)
+	self, _ := rp.WhoAmI()
+
+	var handled string
+	owned := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handled = "owned" })
+	other := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handled = "other" })
+
+	for owner, expected := range map[string]string{self: "owned", "127.0.0.1:1": "other"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderKey, "key")
+		OwnerHandler(rp, owned, other, WithSelector(fixedSelector(owner))).ServeHTTP(httptest.NewRecorder(), r)
+
+		if handled != expected {
+			t.Fatalf("Request of key owned by %s is handled by %s handler, expected: %s", owner, handled, expected)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package ring
+
+import (
+	farm "github.com/dgryski/go-farm"
+)
+
+// jumpTable is a jump consistent hash over buckets of members ordered by address,
+// member with weight w has w buckets. Jump hash needs no memory and is the fastest one,
+// but keys move evenly only when buckets are added or removed at the end, i.e. it suits
+// stable membership best. Successors of key owner are members of the next buckets.
+type jumpTable struct {
+	buckets  []string
+	weighted []WeightedMember
+}
+
+func newJumpTable(members []Member) lookupTable {
+	var buckets []string
+	for _, member := range members {
+		for i := 0; i < member.Weight(); i++ {
+			buckets = append(buckets, member.Address)
+		}
+	}
+
+	return &jumpTable{
+		buckets:  buckets,
+		weighted: weightedMembers(members),
+	}
+}
+
+func (t *jumpTable) lookupN(key string, n int) []string {
+	if len(t.buckets) == 0 {
+		return nil
+	}
+
+	start := jumpHash(farm.Fingerprint64([]byte(key)), len(t.buckets))
+
+	seen := make(map[string]bool, n)
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(t.buckets) && len(nodes) < n; i++ {
+		nodes = appendUnique(nodes, seen, t.buckets[(start+i)%len(t.buckets)])
+	}
+
+	return nodes
+}
+
+func (t *jumpTable) members() []WeightedMember {
+	members := make([]WeightedMember, len(t.weighted))
+	copy(members, t.weighted)
+
+	return members
+}
+
+// jumpHash is a jump consistent hash by Lamping and Veach, it returns bucket in range [0, buckets)
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
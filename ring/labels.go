@@ -0,0 +1,74 @@
+package ring
+
+import (
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+// ApplyDiscoveredLabels shares labels of this node discovered by provider, e.g. weight in hosts file,
+// which overrides weight set by SetWeight. Overridden maps names of labels applied before to values
+// they replaced: labels which aren't discovered anymore get these values back (e.g. weight of
+// --ringpop.weight), labels which had no value are removed. Health of backend is shared by SetBackendHealth only.
+// It returns overridden labels for the next call, the first error doesn't stop applying the rest.
+func ApplyDiscoveredLabels(rp *ringpop.Ringpop, provider discovery.DiscoverProvider, overridden map[string]string) (map[string]string, error) {
+	labeler, ok := provider.(interface {
+		Labels(address string) (map[string]string, bool)
+	})
+	if !ok {
+		return overridden, nil
+	}
+
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return overridden, err
+	}
+
+	nodeLabels, err := rp.Labels()
+	if err != nil {
+		return overridden, err
+	}
+
+	discovered, _ := labeler.Labels(self)
+
+	var firstErr error
+	current := make(map[string]string, len(discovered))
+	for name, value := range discovered {
+		if name == labelBackend {
+			continue
+		}
+
+		old, ok := nodeLabels.Get(name)
+		if original, applied := overridden[name]; applied {
+			current[name] = original
+		} else {
+			current[name] = old
+		}
+		if ok && old == value {
+			continue
+		}
+		if err := nodeLabels.Set(name, value); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for name, original := range overridden {
+		if _, ok := current[name]; ok {
+			continue
+		}
+
+		if original != "" {
+			err = nodeLabels.Set(name, original)
+		} else {
+			_, err = nodeLabels.Remove(name)
+		}
+		if err != nil {
+			// Restoring is retried next time
+			current[name] = original
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return current, firstErr
+}
@@ -0,0 +1,64 @@
+package ring
+
+import (
+	"testing"
+)
+
+// labelingProvider discovers labels of hosts, like hosts file
+type labelingProvider struct {
+	labels map[string]map[string]string
+}
+
+func (p *labelingProvider) Hosts() ([]string, error) {
+	return nil, nil
+}
+
+func (p *labelingProvider) Labels(address string) (map[string]string, bool) {
+	labels, ok := p.labels[address]
+	return labels, ok
+}
+
+func TestApplyDiscoveredLabels(t *testing.T) {
+	rp, _ := newTestRingpop(t)
+	self, _ := rp.WhoAmI()
+	nodeLabels, _ := rp.Labels()
+	if err := SetWeight(rp, 5); err != nil {
+		t.Fatalf("Unable to set weight: %v", err)
+	}
+
+	discovered := map[string]string{LabelWeight: "3", "zone": "a", labelBackend: labelBackendUnhealthy}
+	provider := &labelingProvider{labels: map[string]map[string]string{self: discovered}}
+	overridden, err := ApplyDiscoveredLabels(rp, provider, nil)
+	if err != nil {
+		t.Fatalf("Unable to apply labels: %v", err)
+	}
+	if weight, _ := nodeLabels.Get(LabelWeight); weight != "3" {
+		t.Fatalf("Unexpected weight: %q", weight)
+	}
+	if _, ok := nodeLabels.Get(labelBackend); ok {
+		t.Fatal("Health of backend must not be discovered")
+	}
+	if _, ok := discovered[labelBackend]; !ok {
+		t.Fatal("Labels of provider must not be modified")
+	}
+
+	provider.labels[self] = map[string]string{LabelWeight: "2"}
+	if overridden, err = ApplyDiscoveredLabels(rp, provider, overridden); err != nil {
+		t.Fatalf("Unable to apply labels: %v", err)
+	}
+	if weight, _ := nodeLabels.Get(LabelWeight); weight != "2" {
+		t.Fatalf("Unexpected weight: %q", weight)
+	}
+	if zone, ok := nodeLabels.Get("zone"); ok {
+		t.Fatalf("Label missing in discovery isn't removed: %q", zone)
+	}
+
+	// Weight missing in discovery is restored to the one set by SetWeight
+	provider.labels[self] = map[string]string{}
+	if _, err := ApplyDiscoveredLabels(rp, provider, overridden); err != nil {
+		t.Fatalf("Unable to apply labels: %v", err)
+	}
+	if weight, _ := nodeLabels.Get(LabelWeight); weight != "5" {
+		t.Fatalf("Weight isn't restored: %q", weight)
+	}
+}
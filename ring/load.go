@@ -0,0 +1,111 @@
+package ring
+
+import (
+	"math"
+	"sync"
+
+	"github.com/ozontech/http-ringpop/pkg/metrics"
+)
+
+var (
+	metricBoundedLoadRedirectsTotal = metrics.MustRegisterCounter("bounded_load_redirects_total", "Total number of requests sent to successor because key owner was overloaded")
+)
+
+// LoadTracker counts in-flight requests sent by this node to every node in the ring (including itself).
+// It's a local estimation of nodes load, used by consistent hashing with bounded loads.
+type LoadTracker struct {
+	mu    sync.Mutex
+	loads map[string]int
+	total int
+}
+
+// NewLoadTracker returns new load tracker
+func NewLoadTracker() *LoadTracker {
+	return &LoadTracker{
+		loads: make(map[string]int),
+	}
+}
+
+// Start accounts request sent to given node, returned func must be called when request is done.
+// It's safe to call Start on nil tracker.
+func (t *LoadTracker) Start(node string) func() {
+	if t == nil {
+		return func() {}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.startLocked(node)
+}
+
+func (t *LoadTracker) startLocked(node string) func() {
+	t.loads[node]++
+	t.total++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.total--
+			if t.loads[node]--; t.loads[node] <= 0 {
+				delete(t.loads, node)
+			}
+		})
+	}
+}
+
+// Load returns number of in-flight requests of given node
+func (t *LoadTracker) Load(node string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.loads[node]
+}
+
+// Loads returns number of in-flight requests per node
+func (t *LoadTracker) Loads() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	loads := make(map[string]int, len(t.loads))
+	for node, load := range t.loads {
+		loads[node] = load
+	}
+
+	return loads
+}
+
+// boundedLoadNode returns the first node in ring order that has capacity for one more request.
+// Capacity of every node is (1+epsilon) times the average load including new request.
+func (t *LoadTracker) boundedLoadNode(nodes []string, epsilon float64) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.boundedLoadNodeLocked(nodes, epsilon)
+}
+
+// reserveBoundedLoadNode returns node the same way as boundedLoadNode and accounts request sent to it,
+// so concurrent requests see the load of each other. Returned func must be called when request is done.
+func (t *LoadTracker) reserveBoundedLoadNode(nodes []string, epsilon float64) (string, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node := t.boundedLoadNodeLocked(nodes, epsilon)
+	return node, t.startLocked(node)
+}
+
+func (t *LoadTracker) boundedLoadNodeLocked(nodes []string, epsilon float64) string {
+	capacity := int(math.Ceil((1 + epsilon) * float64(t.total+1) / float64(len(nodes))))
+
+	for _, node := range nodes {
+		if t.loads[node]+1 <= capacity {
+			return node
+		}
+	}
+
+	// It's not possible when total load is counted correctly, but the owner is the safest choice
+	return nodes[0]
+}
@@ -0,0 +1,77 @@
+package ring
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestLoadTrackerBoundedLoadNode(t *testing.T) {
+	loads := NewLoadTracker()
+	nodes := []string{"owner", "successor", "another"}
+
+	// Capacity is ceil(1.5 * (total + 1) / 3), so it's 1 for the first two requests
+	if node := loads.boundedLoadNode(nodes, 0.5); node != "owner" {
+		t.Fatalf("Unexpected node: %s, expected: owner", node)
+	}
+	done := loads.Start("owner")
+
+	if node := loads.boundedLoadNode(nodes, 0.5); node != "successor" {
+		t.Fatalf("Unexpected node: %s, expected: successor", node)
+	}
+	loads.Start("successor")
+
+	// Capacity is 2 now
+	if node := loads.boundedLoadNode(nodes, 0.5); node != "owner" {
+		t.Fatalf("Unexpected node: %s, expected: owner", node)
+	}
+
+	done()
+	done() // done is idempotent
+
+	if load := loads.Load("owner"); load != 0 {
+		t.Fatalf("Unexpected load: %d, expected: 0", load)
+	}
+}
+
+func TestLoadTrackerReserveConcurrently(t *testing.T) {
+	loads := NewLoadTracker()
+	nodes := []string{"owner", "successor", "another"}
+	epsilon := 0.25
+	requests := 300
+
+	start := make(chan struct{})
+	reserved := make(chan func(), requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, done := loads.reserveBoundedLoadNode(nodes, epsilon)
+			reserved <- done
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(reserved)
+
+	capacity := int(math.Ceil((1 + epsilon) * float64(requests) / float64(len(nodes))))
+	for node, load := range loads.Loads() {
+		if load > capacity {
+			t.Fatalf("Load of %s is %d, it exceeds capacity %d", node, load, capacity)
+		}
+	}
+
+	for done := range reserved {
+		done()
+	}
+	if len(loads.Loads()) != 0 {
+		t.Fatalf("Unexpected loads after requests are done: %v", loads.Loads())
+	}
+}
+
+func TestLoadTrackerNil(t *testing.T) {
+	var loads *LoadTracker
+	loads.Start("node")()
+}
@@ -0,0 +1,132 @@
+package ring
+
+import (
+	farm "github.com/dgryski/go-farm"
+)
+
+const (
+	// maglevTableSize is a minimal size of Maglev lookup table, it must be prime
+	maglevTableSize = 65537
+	// maglevEntriesPerWeight is a minimal number of table entries per unit of weight
+	maglevEntriesPerWeight = 100
+	// maglevSkipSeed is a seed of hash that defines member's permutation step
+	maglevSkipSeed = 0x6d61676c6576
+)
+
+// maglevTable is a Maglev hashing: members fill lookup table in turns following their own permutations,
+// so every member gets number of entries proportional to its weight. Key is owned by member of its entry,
+// successors are the next distinct members in the table.
+type maglevTable struct {
+	entries  []string
+	weighted []WeightedMember
+}
+
+func newMaglevTable(members []Member) lookupTable {
+	var total int
+	for _, member := range members {
+		total += member.Weight()
+	}
+
+	size := uint64(maglevTableSize)
+	if min := uint64(total * maglevEntriesPerWeight); min > size {
+		size = nextPrime(min)
+	}
+
+	type permutation struct {
+		offset, skip, next uint64
+	}
+	permutations := make([]permutation, len(members))
+	for i, member := range members {
+		address := []byte(member.Address)
+		permutations[i] = permutation{
+			offset: farm.Fingerprint64(address) % size,
+			skip:   farm.Hash64WithSeed(address, maglevSkipSeed)%(size-1) + 1,
+		}
+	}
+
+	var filled uint64
+	entries := make([]string, size)
+	counts := make([]int, len(members))
+	for filled < size && len(members) > 0 {
+		for i, member := range members {
+			// Member with weight w takes w entries per turn
+			for w := 0; w < member.Weight() && filled < size; w++ {
+				p := &permutations[i]
+				for {
+					entry := (p.offset + p.next*p.skip) % size
+					p.next++
+					if entries[entry] == "" {
+						entries[entry] = member.Address
+						counts[i]++
+						filled++
+						break
+					}
+				}
+			}
+		}
+	}
+
+	weighted := make([]WeightedMember, 0, len(members))
+	for i, member := range members {
+		weighted = append(weighted, WeightedMember{
+			Address: member.Address,
+			Weight:  member.Weight(),
+			Points:  counts[i],
+			Share:   float64(counts[i]) / float64(size),
+		})
+	}
+
+	if len(members) == 0 {
+		entries = nil
+	}
+
+	return &maglevTable{
+		entries:  entries,
+		weighted: weighted,
+	}
+}
+
+func (t *maglevTable) lookupN(key string, n int) []string {
+	if len(t.entries) == 0 {
+		return nil
+	}
+
+	start := farm.Fingerprint64([]byte(key)) % uint64(len(t.entries))
+
+	seen := make(map[string]bool, n)
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(t.entries) && len(nodes) < n && len(nodes) < len(t.weighted); i++ {
+		nodes = appendUnique(nodes, seen, t.entries[(start+uint64(i))%uint64(len(t.entries))])
+	}
+
+	return nodes
+}
+
+func (t *maglevTable) members() []WeightedMember {
+	members := make([]WeightedMember, len(t.weighted))
+	copy(members, t.weighted)
+
+	return members
+}
+
+// nextPrime returns the smallest prime number that is not less than n
+func nextPrime(n uint64) uint64 {
+	for ; ; n++ {
+		if isPrime(n) {
+			return n
+		}
+	}
+}
+
+func isPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for d := uint64(2); d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+
+	return true
+}
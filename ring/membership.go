@@ -0,0 +1,203 @@
+package ring
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/events"
+	"github.com/uber/ringpop-go/membership"
+	"github.com/uber/ringpop-go/swim"
+)
+
+// Member is a member of the ring as it's seen by this node
+type Member struct {
+	Address     string            `json:"address"`
+	Status      string            `json:"status"`
+	Incarnation int64             `json:"incarnation"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// Reachable returns true if member takes part in the ring
+func (m Member) Reachable() bool {
+	return m.Status == swim.Alive || m.Status == swim.Suspect
+}
+
+func (m Member) equal(other Member) bool {
+	if m.Address != other.Address || m.Status != other.Status || m.Incarnation != other.Incarnation ||
+		len(m.Labels) != len(other.Labels) {
+		return false
+	}
+
+	for name, value := range m.Labels {
+		if other.Labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Membership keeps track of ring members, their statuses and labels using ringpop events
+type Membership struct {
+	ringpop *ringpop.Ringpop
+
+	mu        sync.RWMutex
+	members   map[string]Member
+	version   uint64
+	listeners []func()
+}
+
+// NewMembership returns new membership registered as ringpop event listener,
+// it must be created before ringpop is bootstrapped to see all changes
+func NewMembership(rp *ringpop.Ringpop) *Membership {
+	m := &Membership{
+		ringpop: rp,
+		members: make(map[string]Member),
+	}
+	rp.AddListener(m)
+
+	return m
+}
+
+// HandleEvent applies membership changes, it implements events.EventListener interface
+func (m *Membership) HandleEvent(event events.Event) {
+	switch e := event.(type) {
+	case swim.MemberlistChangesAppliedEvent:
+		members := make([]Member, 0, len(e.Changes))
+		for _, change := range e.Changes {
+			members = append(members, Member{
+				Address:     change.Address,
+				Status:      change.Status,
+				Incarnation: change.Incarnation,
+				Labels:      change.Labels,
+			})
+		}
+		m.update(members)
+
+	case membership.ChangeEvent:
+		// Changes of local member (e.g. labels) are emitted only this way
+		var members []Member
+		for _, change := range e.Changes {
+			if member, ok := change.After.(swim.Member); ok {
+				members = append(members, Member{
+					Address:     member.Address,
+					Status:      member.Status,
+					Incarnation: member.Incarnation,
+					Labels:      member.Labels,
+				})
+			}
+		}
+		m.update(members)
+
+	case events.Ready:
+		// Local member is created before listener is added, so its changes are never seen
+		m.sync()
+	}
+}
+
+// sync applies current state of reachable members, it fails only if ringpop is not ready
+func (m *Membership) sync() error {
+	var members []Member
+	_, err := m.ringpop.GetReachableMembers(func(member swim.Member) bool {
+		members = append(members, Member{
+			Address:     member.Address,
+			Status:      member.Status,
+			Incarnation: member.Incarnation,
+			Labels:      member.Labels,
+		})
+		return false
+	})
+	if err != nil {
+		return err
+	}
+
+	m.update(members)
+
+	return nil
+}
+
+func (m *Membership) update(members []Member) {
+	if len(members) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	var changed bool
+	for _, member := range members {
+		current, ok := m.members[member.Address]
+		if ok && current.Incarnation > member.Incarnation {
+			continue
+		}
+
+		if member.Status == swim.Tombstone {
+			if ok {
+				delete(m.members, member.Address)
+				changed = true
+			}
+			continue
+		}
+
+		if !ok || !current.equal(member) {
+			m.members[member.Address] = member
+			changed = true
+		}
+	}
+	if !changed {
+		m.mu.Unlock()
+		return
+	}
+	m.version++
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+// OnChange registers func that is called after membership changes
+func (m *Membership) OnChange(fn func()) {
+	m.mu.Lock()
+	m.listeners = append(m.listeners, fn)
+	m.mu.Unlock()
+}
+
+// Version returns number that is changed every time membership changes
+func (m *Membership) Version() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.version
+}
+
+// Members returns all known members (including faulty ones) ordered by address
+func (m *Membership) Members() []Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := make([]Member, 0, len(m.members))
+	for _, member := range m.members {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Address < members[j].Address
+	})
+
+	return members
+}
+
+// ReachableMembers returns members that take part in the ring ordered by address
+func (m *Membership) ReachableMembers() []Member {
+	members := m.Members()
+
+	reachable := members[:0]
+	for _, member := range members {
+		if member.Reachable() {
+			reachable = append(reachable, member)
+		}
+	}
+
+	return reachable
+}
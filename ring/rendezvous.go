@@ -0,0 +1,99 @@
+package ring
+
+import (
+	"math"
+
+	farm "github.com/dgryski/go-farm"
+)
+
+// rendezvousTable is a weighted rendezvous (highest random weight) hashing: every member gets
+// a score for the key and the key is owned by the member with the highest score.
+// When member leaves, only its keys move and they are spread evenly among the others.
+type rendezvousTable struct {
+	nodes    []rendezvousNode
+	weighted []WeightedMember
+}
+
+type rendezvousNode struct {
+	address string
+	hash    uint64
+	weight  float64
+}
+
+func newRendezvousTable(members []Member) lookupTable {
+	nodes := make([]rendezvousNode, 0, len(members))
+	for _, member := range members {
+		nodes = append(nodes, rendezvousNode{
+			address: member.Address,
+			hash:    farm.Fingerprint64([]byte(member.Address)),
+			weight:  float64(member.Weight()),
+		})
+	}
+
+	return &rendezvousTable{
+		nodes:    nodes,
+		weighted: weightedMembers(members),
+	}
+}
+
+func (t *rendezvousTable) lookupN(key string, n int) []string {
+	if n > len(t.nodes) {
+		n = len(t.nodes)
+	}
+
+	hash := farm.Fingerprint64([]byte(key))
+
+	type score struct {
+		address string
+		value   float64
+	}
+	scores := make([]score, 0, len(t.nodes))
+	for _, node := range t.nodes {
+		scores = append(scores, score{
+			address: node.address,
+			value:   rendezvousScore(hash, node),
+		})
+	}
+
+	// Usually n is small, so partial selection sort is cheaper than sorting all scores
+	nodes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		best := i
+		for j := i + 1; j < len(scores); j++ {
+			if scores[j].value > scores[best].value ||
+				scores[j].value == scores[best].value && scores[j].address < scores[best].address {
+				best = j
+			}
+		}
+		scores[i], scores[best] = scores[best], scores[i]
+		nodes = append(nodes, scores[i].address)
+	}
+
+	return nodes
+}
+
+func (t *rendezvousTable) members() []WeightedMember {
+	members := make([]WeightedMember, len(t.weighted))
+	copy(members, t.weighted)
+
+	return members
+}
+
+// rendezvousScore returns weight / -ln(u), where u is uniformly distributed in (0, 1)
+// hash of key and node. Probability of node to get the highest score is proportional to its weight.
+func rendezvousScore(keyHash uint64, node rendezvousNode) float64 {
+	u := (float64(mix64(keyHash^node.hash)>>11) + 0.5) / (1 << 53)
+
+	return node.weight / -math.Log(u)
+}
+
+// mix64 is a splitmix64 finalizer, it spreads bits of combined hashes
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
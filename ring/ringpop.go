@@ -3,7 +3,7 @@ package ring
 import (
 	"errors"
 	"fmt"
-	
+
 	"github.com/uber-common/bark"
 	"github.com/uber/ringpop-go"
 	"github.com/uber/ringpop-go/discovery"
@@ -12,6 +12,13 @@ import (
 	"github.com/uber/tchannel-go"
 )
 
+const (
+	// labelBackend is a ringpop label used to share health of member's HTTP backend
+	labelBackend          = "backend"
+	labelBackendHealthy   = "healthy"
+	labelBackendUnhealthy = "unhealthy"
+)
+
 var errorRingpopIsNotReady = errors.New("Ringpop is not ready")
 
 // NewChannel returns new TChannel used for communication between nodes in ring
@@ -43,18 +50,194 @@ func BootstrapRingpop(rp *ringpop.Ringpop, provider discovery.DiscoverProvider)
 	return err
 }
 
+// ResolveOption changes the way destination node is resolved
+type ResolveOption func(*resolveOptions)
+
+type resolveOptions struct {
+	lookupN func(key string, n int) ([]string, error)
+
+	loads   *LoadTracker
+	epsilon float64
+}
+
+func newResolveOptions(rp *ringpop.Ringpop, options []ResolveOption) *resolveOptions {
+	opts := &resolveOptions{
+		lookupN: rp.LookupN,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+
+	return opts
+}
+
+// WithBoundedLoad enables consistent hashing with bounded loads: load of every node
+// is capped at (1+epsilon) times the average load, requests to overloaded owner
+// go to the next node in the ring that has capacity
+func WithBoundedLoad(loads *LoadTracker, epsilon float64) ResolveOption {
+	return func(opts *resolveOptions) {
+		opts.loads = loads
+		opts.epsilon = epsilon
+	}
+}
+
+// WithSelector makes keys owned by members chosen by given selector instead of ringpop's hashring
+func WithSelector(selector NodeSelector) ResolveOption {
+	return func(opts *resolveOptions) {
+		opts.lookupN = selector.LookupN
+	}
+}
+
 // ResolveDestinationNode finds out responsible node from hashring by given key
-func ResolveDestinationNode(rp *ringpop.Ringpop, key string) (string, error) {
+//
+// Members with unhealthy HTTP backend are skipped: request goes to the next
+// healthy node in the ring, so keys of unhealthy member are spread among its successors.
+func ResolveDestinationNode(rp *ringpop.Ringpop, key string, options ...ResolveOption) (string, error) {
+	node, _, err := resolveDestinationNode(rp, key, false, options)
+	return node, err
+}
+
+// ReserveDestinationNode resolves node the same way as ResolveDestinationNode. With bounded loads
+// request is accounted in load of the node at once, so concurrent requests can't push the node over
+// its capacity. Returned func must be called when request is done.
+func ReserveDestinationNode(rp *ringpop.Ringpop, key string, options ...ResolveOption) (string, func(), error) {
+	return resolveDestinationNode(rp, key, true, options)
+}
+
+func resolveDestinationNode(rp *ringpop.Ringpop, key string, reserve bool, options []ResolveOption) (string, func(), error) {
+	noop := func() {}
+	if !rp.Ready() {
+		return "", noop, errorRingpopIsNotReady
+	}
+
+	opts := newResolveOptions(rp, options)
+
+	unhealthy, err := unhealthyMembers(rp)
+	if err != nil {
+		return "", noop, err
+	}
+
+	if opts.loads == nil {
+		nodes, err := resolveHealthyNodes(key, 1, unhealthy, opts)
+		if err != nil {
+			return "", noop, err
+		}
+
+		return nodes[0], noop, nil
+	}
+
+	count, err := rp.CountReachableMembers()
+	if err != nil {
+		return "", noop, err
+	}
+
+	nodes, err := resolveHealthyNodes(key, count, unhealthy, opts)
+	if err != nil {
+		return "", noop, err
+	}
+
+	dest, done := opts.loads.boundedLoadNode(nodes, opts.epsilon), noop
+	if reserve {
+		dest, done = opts.loads.reserveBoundedLoadNode(nodes, opts.epsilon)
+	}
+	if dest != nodes[0] {
+		metricBoundedLoadRedirectsTotal.Inc()
+	}
+
+	return dest, done, nil
+}
+
+// ResolveDestinationNodes returns up to n nodes responsible for given key:
+// the owner followed by its successors in the ring. Members with unhealthy HTTP backend
+// are skipped the same way as in ResolveDestinationNode.
+func ResolveDestinationNodes(rp *ringpop.Ringpop, key string, n int, options ...ResolveOption) ([]string, error) {
 	if !rp.Ready() {
-		return "", errorRingpopIsNotReady
+		return nil, errorRingpopIsNotReady
+	}
+
+	unhealthy, err := unhealthyMembers(rp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveHealthyNodes(key, n, unhealthy, newResolveOptions(rp, options))
+}
+
+// LookupNodes returns up to n nodes of key as they are placed by the ring: the owner followed by
+// its successors. Unlike ResolveDestinationNodes, health of backends isn't taken into account.
+func LookupNodes(rp *ringpop.Ringpop, key string, n int, options ...ResolveOption) ([]string, error) {
+	if !rp.Ready() {
+		return nil, errorRingpopIsNotReady
+	}
+
+	nodes, err := newResolveOptions(rp, options).lookupN(key, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("could not find destination for key")
+	}
+
+	return nodes, nil
+}
+
+func resolveHealthyNodes(key string, n int, unhealthy map[string]bool, opts *resolveOptions) ([]string, error) {
+	nodes, err := opts.lookupN(key, n+len(unhealthy))
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("could not find destination for key")
+	}
+
+	healthy := make([]string, 0, n)
+	for _, node := range nodes {
+		if !unhealthy[node] {
+			healthy = append(healthy, node)
+		}
+		if len(healthy) == n {
+			break
+		}
+	}
+
+	// All backends are unhealthy, there is no better choice than the owners
+	if len(healthy) == 0 {
+		if len(nodes) > n {
+			nodes = nodes[:n]
+		}
+		return nodes, nil
 	}
 
-	dest, err := rp.Lookup(key)
+	return healthy, nil
+}
+
+// SetBackendHealth shares health of local HTTP backend with other members of the ring
+func SetBackendHealth(rp *ringpop.Ringpop, healthy bool) error {
+	labels, err := rp.Labels()
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	value := labelBackendHealthy
+	if !healthy {
+		value = labelBackendUnhealthy
+	}
+
+	return labels.Set(labelBackend, value)
+}
+
+func unhealthyMembers(rp *ringpop.Ringpop) (map[string]bool, error) {
+	members, err := rp.GetReachableMembers(swim.MemberWithLabelAndValue(labelBackend, labelBackendUnhealthy))
+	if err != nil {
+		return nil, err
+	}
+
+	unhealthy := make(map[string]bool, len(members))
+	for _, member := range members {
+		unhealthy[member] = true
 	}
 
-	return dest, nil
+	return unhealthy, nil
 }
 
 // SetLogger sets default logger for ringpop
@@ -0,0 +1,182 @@
+package ring
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Algorithms of key to node mapping supported by Selector
+const (
+	// AlgorithmRingpop is ringpop's own hashring, weights of members are ignored
+	AlgorithmRingpop = "ringpop"
+	// AlgorithmRing is a consistent hash ring with farmhash replica points, members with weight 1
+	// are placed exactly as in ringpop's hashring
+	AlgorithmRing = "ring"
+	// AlgorithmRendezvous is a weighted rendezvous (highest random weight) hashing
+	AlgorithmRendezvous = "rendezvous"
+	// AlgorithmMaglev is a Maglev hashing with lookup table
+	AlgorithmMaglev = "maglev"
+	// AlgorithmJump is a jump consistent hash over members ordered by address,
+	// it moves few keys only when the last member is added or removed
+	AlgorithmJump = "jump"
+)
+
+var errorRingIsEmpty = errors.New("could not find destination for key: ring is empty")
+
+// tableBuilders build lookup table of every supported algorithm
+var tableBuilders = map[string]func(members []Member) lookupTable{
+	AlgorithmRing:       newRingTable,
+	AlgorithmRendezvous: newRendezvousTable,
+	AlgorithmMaglev:     newMaglevTable,
+	AlgorithmJump:       newJumpTable,
+}
+
+// NodeSelector maps key to nodes responsible for it
+type NodeSelector interface {
+	// LookupN returns up to n unique nodes responsible for key, the owner goes first
+	LookupN(key string, n int) ([]string, error)
+}
+
+// lookupTable is an immutable snapshot of members placed by some algorithm
+type lookupTable interface {
+	lookupN(key string, n int) []string
+	members() []WeightedMember
+}
+
+// Algorithms returns names of algorithms taking weights of members into account,
+// AlgorithmRingpop is supported by Selector too
+func Algorithms() []string {
+	algorithms := make([]string, 0, len(tableBuilders))
+	for algorithm := range tableBuilders {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+
+	return algorithms
+}
+
+// Selector maps keys to reachable members using chosen algorithm, members' weights are taken into account.
+// Lookup table is rebuilt lazily when membership changes.
+type Selector struct {
+	algorithm  string
+	build      func(members []Member) lookupTable
+	membership *Membership
+
+	mu      sync.Mutex
+	version uint64
+	table   lookupTable
+}
+
+// NewSelector returns selector of given algorithm over reachable members
+func NewSelector(algorithm string, m *Membership) (*Selector, error) {
+	build, ok := tableBuilders[algorithm]
+	if algorithm == AlgorithmRingpop {
+		build, ok = newRingpopTable, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown hashing algorithm: %s", algorithm)
+	}
+
+	return &Selector{
+		algorithm:  algorithm,
+		build:      build,
+		membership: m,
+	}, nil
+}
+
+// NewStaticSelector returns selector of given algorithm over fixed set of members,
+// it's useful for benchmarks and distribution reports
+func NewStaticSelector(algorithm string, members []Member) (*Selector, error) {
+	s, err := NewSelector(algorithm, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]Member, len(members))
+	copy(sorted, members)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Address < sorted[j].Address
+	})
+	s.table = s.build(sorted)
+
+	return s, nil
+}
+
+// Algorithm returns name of selector's algorithm
+func (s *Selector) Algorithm() string {
+	return s.algorithm
+}
+
+// LookupN returns n unique members responsible for given key
+func (s *Selector) LookupN(key string, n int) ([]string, error) {
+	nodes := s.current().lookupN(key, n)
+	if len(nodes) == 0 {
+		return nil, errorRingIsEmpty
+	}
+
+	return nodes, nil
+}
+
+// Members returns members with their weights and owned parts of key space
+func (s *Selector) Members() []WeightedMember {
+	return s.current().members()
+}
+
+// current returns lookup table of actual membership
+func (s *Selector) current() lookupTable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.membership == nil {
+		return s.table
+	}
+
+	version := s.membership.Version()
+	if s.table == nil || version != s.version {
+		s.table = s.build(s.membership.ReachableMembers())
+		s.version = version
+	}
+
+	return s.table
+}
+
+// WeightedMember describes place of member in lookup table
+type WeightedMember struct {
+	Address string `json:"address"`
+	Weight  int    `json:"weight"`
+	// Points is a number of replica points or table entries of member, if algorithm has them
+	Points int `json:"points,omitempty"`
+	// Share is a part of key space owned by member
+	Share float64 `json:"share"`
+}
+
+// weightedMembers returns members with shares proportional to their weights
+func weightedMembers(members []Member) []WeightedMember {
+	var total int
+	for _, member := range members {
+		total += member.Weight()
+	}
+
+	weighted := make([]WeightedMember, 0, len(members))
+	for _, member := range members {
+		weighted = append(weighted, WeightedMember{
+			Address: member.Address,
+			Weight:  member.Weight(),
+			Share:   float64(member.Weight()) / float64(total),
+		})
+	}
+
+	return weighted
+}
+
+// appendUnique appends node to nodes if it's not there yet
+func appendUnique(nodes []string, seen map[string]bool, node string) []string {
+	if seen[node] {
+		return nodes
+	}
+	seen[node] = true
+
+	return append(nodes, node)
+}
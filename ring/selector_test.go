@@ -0,0 +1,167 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/uber/ringpop-go/swim"
+)
+
+func newTestSelector(t testing.TB, algorithm string, members ...Member) *Selector {
+	m := &Membership{members: make(map[string]Member)}
+	m.update(members)
+
+	s, err := NewSelector(algorithm, m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return s
+}
+
+func testMembers(count int) []Member {
+	members := make([]Member, 0, count)
+	for i := 0; i < count; i++ {
+		members = append(members, Member{Address: fmt.Sprintf("10.0.0.%d:5000", i+1), Status: swim.Alive})
+	}
+
+	return members
+}
+
+func TestSelectorShares(t *testing.T) {
+	for _, algorithm := range Algorithms() {
+		s := newTestSelector(t, algorithm,
+			Member{Address: "small:5000", Status: swim.Alive},
+			Member{Address: "large:5000", Status: swim.Alive, Labels: map[string]string{LabelWeight: "3"}},
+			Member{Address: "faulty:5000", Status: swim.Faulty},
+		)
+
+		members := s.Members()
+		if len(members) != 2 {
+			t.Fatalf("%s: unexpected members: %+v, expected only reachable ones", algorithm, members)
+		}
+
+		owned := make(map[string]int)
+		for i := 0; i < 10000; i++ {
+			nodes, err := s.LookupN(fmt.Sprintf("key-%d", i), 1)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", algorithm, err)
+			}
+			owned[nodes[0]]++
+		}
+
+		for _, member := range members {
+			expected := 0.25 * float64(member.Weight)
+			if math.Abs(member.Share-expected) > 0.05 {
+				t.Fatalf("%s: unexpected share of %s: %f, expected: %f", algorithm, member.Address, member.Share, expected)
+			}
+			if share := float64(owned[member.Address]) / 10000; math.Abs(share-expected) > 0.05 {
+				t.Fatalf("%s: %s owns %f of keys, expected: %f", algorithm, member.Address, share, expected)
+			}
+		}
+	}
+}
+
+func TestSelectorLookupN(t *testing.T) {
+	for _, algorithm := range Algorithms() {
+		s := newTestSelector(t, algorithm, testMembers(5)...)
+
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key-%d", i)
+
+			nodes, err := s.LookupN(key, 10)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", algorithm, err)
+			}
+			if len(nodes) != 5 {
+				t.Fatalf("%s: unexpected nodes: %v, expected all 5 members", algorithm, nodes)
+			}
+
+			seen := make(map[string]bool)
+			for _, node := range nodes {
+				if seen[node] {
+					t.Fatalf("%s: duplicate node in %v", algorithm, nodes)
+				}
+				seen[node] = true
+			}
+
+			owner, _ := s.LookupN(key, 1)
+			if owner[0] != nodes[0] {
+				t.Fatalf("%s: owner %s differs from the first node of %v", algorithm, owner[0], nodes)
+			}
+		}
+	}
+}
+
+func TestSelectorTablesOfHugeWeight(t *testing.T) {
+	members := []Member{
+		{Address: "small:5000", Status: swim.Alive},
+		{Address: "huge:5000", Status: swim.Alive, Labels: map[string]string{LabelWeight: "65535"}},
+	}
+
+	if size := len(newMaglevTable(members).(*maglevTable).entries); size > maglevTableSize {
+		t.Fatalf("Unexpected size of Maglev table: %d, weight must be capped by %d", size, MaxWeight)
+	}
+	if buckets := len(newJumpTable(members).(*jumpTable).buckets); buckets != MaxWeight+1 {
+		t.Fatalf("Unexpected number of jump buckets: %d, expected: %d", buckets, MaxWeight+1)
+	}
+}
+
+func TestRendezvousMinimalMovement(t *testing.T) {
+	members := testMembers(5)
+	before, _ := NewStaticSelector(AlgorithmRendezvous, members)
+	after, _ := NewStaticSelector(AlgorithmRendezvous, members[1:])
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+
+		was, _ := before.LookupN(key, 1)
+		now, _ := after.LookupN(key, 1)
+		if was[0] != members[0].Address && was[0] != now[0] {
+			t.Fatalf("Key %s moved from %s to %s, but only keys of removed member must move", key, was[0], now[0])
+		}
+	}
+}
+
+func TestSelectorEmpty(t *testing.T) {
+	for _, algorithm := range Algorithms() {
+		if _, err := newTestSelector(t, algorithm).LookupN("key", 1); err == nil {
+			t.Fatalf("%s: expected error for empty ring", algorithm)
+		}
+	}
+}
+
+func TestSelectorUnknownAlgorithm(t *testing.T) {
+	if _, err := NewSelector("unknown", nil); err == nil {
+		t.Fatal("Expected error for unknown algorithm")
+	}
+}
+
+func BenchmarkSelectorLookup(b *testing.B) {
+	for _, algorithm := range Algorithms() {
+		b.Run(algorithm, func(b *testing.B) {
+			s, _ := NewStaticSelector(algorithm, testMembers(32))
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key-%d", i)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.LookupN(keys[i%len(keys)], 1)
+			}
+		})
+	}
+}
+
+func BenchmarkSelectorBuild(b *testing.B) {
+	members := testMembers(32)
+	for _, algorithm := range Algorithms() {
+		b.Run(algorithm, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewStaticSelector(algorithm, members)
+			}
+		})
+	}
+}
@@ -91,8 +91,8 @@ func (h ringpopRequestHandler) Handle(ctx context.Context, args *raw.Args) (*raw
 
 	respWriter := NewResponseWriter()
 
-	// Serve request on HTTP backend
-	h.backend.ServeHTTP(respWriter, request)
+	// Serve request on HTTP backend, it's canceled with forwarded request
+	h.backend.ServeHTTP(respWriter, request.WithContext(ctx))
 
 	rawResponse := []byte{}
 	buffer := bytes.NewBuffer(rawResponse)
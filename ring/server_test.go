@@ -0,0 +1,31 @@
+package ring
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/tchannel-go/raw"
+)
+
+func TestRingpopRequestHandlerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var canceled bool
+	h := ringpopRequestHandler{
+		backend: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			canceled = r.Context().Err() != nil
+		}),
+		logger: bark.NewLoggerFromLogrus(logrus.New()),
+	}
+
+	if _, err := h.Handle(ctx, &raw.Args{Arg3: []byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !canceled {
+		t.Fatal("Backend request must be canceled with forwarded request")
+	}
+}
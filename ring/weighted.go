@@ -0,0 +1,165 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	farm "github.com/dgryski/go-farm"
+	"github.com/uber/ringpop-go"
+)
+
+const (
+	// LabelWeight is a ringpop label used to share weight of member, discovery providers set it too
+	LabelWeight = "weight"
+
+	// MaxWeight limits weight of member: lookup tables grow with weights and they are rebuilt
+	// on every membership change, so a mistyped label mustn't stall every node
+	MaxWeight = 100
+
+	// replicaPoints is a number of replica points of member with weight 1,
+	// it's the same as in ringpop's hashring
+	replicaPoints = 100
+)
+
+// SetWeight shares weight of this node with other members of the ring
+func SetWeight(rp *ringpop.Ringpop, weight int) error {
+	if weight < 1 || weight > MaxWeight {
+		return fmt.Errorf("weight must be from 1 to %d: %d", MaxWeight, weight)
+	}
+
+	labels, err := rp.Labels()
+	if err != nil {
+		return err
+	}
+
+	return labels.Set(LabelWeight, strconv.Itoa(weight))
+}
+
+// Weight returns weight of member, it's 1 if weight is not set or invalid and MaxWeight if it's greater
+func (m Member) Weight() int {
+	weight, err := strconv.Atoi(m.Labels[LabelWeight])
+	if err != nil || weight < 1 {
+		return 1
+	}
+	if weight > MaxWeight {
+		return MaxWeight
+	}
+
+	return weight
+}
+
+// WithWeight returns copy of member with given weight
+func (m Member) WithWeight(weight int) Member {
+	labels := make(map[string]string, len(m.Labels)+1)
+	for name, value := range m.Labels {
+		labels[name] = value
+	}
+	labels[LabelWeight] = strconv.Itoa(weight)
+	m.Labels = labels
+
+	return m
+}
+
+// ringTable is a consistent hash ring where every member has number of replica points
+// proportional to its weight, so it owns proportional part of key space.
+// Members with weight 1 are placed exactly as in ringpop's hashring.
+type ringTable struct {
+	points   []replicaPoint
+	weighted []WeightedMember
+}
+
+type replicaPoint struct {
+	hash    uint32
+	address string
+	index   int
+}
+
+// newRingpopTable places members the same way as ringpop's hashring does, i.e. with weight 1
+func newRingpopTable(members []Member) lookupTable {
+	unweighted := make([]Member, 0, len(members))
+	for _, member := range members {
+		unweighted = append(unweighted, member.WithWeight(1))
+	}
+
+	return newRingTable(unweighted)
+}
+
+func newRingTable(members []Member) lookupTable {
+	var points []replicaPoint
+	weighted := make([]WeightedMember, 0, len(members))
+	for _, member := range members {
+		count := replicaPoints * member.Weight()
+		for i := 0; i < count; i++ {
+			points = append(points, replicaPoint{
+				hash:    farm.Fingerprint32([]byte(fmt.Sprintf("%s%v", member.Address, i))),
+				address: member.Address,
+				index:   i,
+			})
+		}
+
+		weighted = append(weighted, WeightedMember{
+			Address: member.Address,
+			Weight:  member.Weight(),
+			Points:  count,
+		})
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		if points[i].address != points[j].address {
+			return points[i].address < points[j].address
+		}
+		return points[i].index < points[j].index
+	})
+
+	// Every point owns keys with hashes between previous point (exclusive) and itself
+	shares := make(map[string]float64, len(members))
+	for i, point := range points {
+		var arc uint32
+		if i == 0 {
+			arc = point.hash + (math.MaxUint32 - points[len(points)-1].hash)
+		} else {
+			arc = point.hash - points[i-1].hash
+		}
+		shares[point.address] += float64(arc) / math.MaxUint32
+	}
+
+	for i := range weighted {
+		weighted[i].Share = shares[weighted[i].Address]
+	}
+
+	return &ringTable{
+		points:   points,
+		weighted: weighted,
+	}
+}
+
+func (t *ringTable) lookupN(key string, n int) []string {
+	if len(t.points) == 0 {
+		return nil
+	}
+
+	hash := farm.Fingerprint32([]byte(key))
+	start := sort.Search(len(t.points), func(i int) bool {
+		return t.points[i].hash >= hash
+	})
+
+	seen := make(map[string]bool, n)
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(t.points) && len(nodes) < n; i++ {
+		nodes = appendUnique(nodes, seen, t.points[(start+i)%len(t.points)].address)
+	}
+
+	return nodes
+}
+
+func (t *ringTable) members() []WeightedMember {
+	members := make([]WeightedMember, len(t.weighted))
+	copy(members, t.weighted)
+
+	return members
+}
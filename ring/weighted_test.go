@@ -0,0 +1,139 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	farm "github.com/dgryski/go-farm"
+	"github.com/uber/ringpop-go/hashring"
+	"github.com/uber/ringpop-go/swim"
+)
+
+func newTestWeightedRing(t testing.TB, members ...Member) *Selector {
+	return newTestSelector(t, AlgorithmRing, members...)
+}
+
+func TestWeightedRingMatchesHashring(t *testing.T) {
+	addresses := []string{"10.0.0.1:5000", "10.0.0.2:5000", "10.0.0.3:5000"}
+
+	var members []Member
+	hr := hashring.New(farm.Fingerprint32, replicaPoints)
+	for _, address := range addresses {
+		members = append(members, Member{Address: address, Status: swim.Alive})
+		hr.AddMembers(swim.Member{Address: address, Status: swim.Alive})
+	}
+
+	// Ringpop algorithm ignores weights, so it matches hashring even with them
+	weighted := append([]Member(nil), members...)
+	weighted[0] = weighted[0].WithWeight(3)
+
+	for algorithm, members := range map[string][]Member{
+		AlgorithmRing:    members,
+		AlgorithmRingpop: weighted,
+	} {
+		r := newTestSelector(t, algorithm, members...)
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+
+			expected := hr.LookupN(key, 2)
+			nodes, err := r.LookupN(key, 2)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if fmt.Sprint(nodes) != fmt.Sprint(expected) {
+				t.Fatalf("%s: key %s: unexpected nodes: %v, expected: %v", algorithm, key, nodes, expected)
+			}
+		}
+	}
+}
+
+func TestWeightedRingShares(t *testing.T) {
+	r := newTestWeightedRing(t,
+		Member{Address: "small:5000", Status: swim.Alive},
+		Member{Address: "large:5000", Status: swim.Alive, Labels: map[string]string{LabelWeight: "3"}},
+		Member{Address: "faulty:5000", Status: swim.Faulty},
+	)
+
+	members := r.Members()
+	if len(members) != 2 {
+		t.Fatalf("Unexpected members: %+v, expected only reachable ones", members)
+	}
+
+	for _, member := range members {
+		expected := 0.25 * float64(member.Weight)
+		if math.Abs(member.Share-expected) > 0.05 {
+			t.Fatalf("Unexpected share of %s: %f, expected: %f", member.Address, member.Share, expected)
+		}
+	}
+}
+
+func TestWeightedRingEmpty(t *testing.T) {
+	if _, err := newTestWeightedRing(t).LookupN("key", 1); err == nil {
+		t.Fatal("Expected error for empty ring")
+	}
+}
+
+func TestMemberWeight(t *testing.T) {
+	for value, expected := range map[string]int{"": 1, "3": 3, "0": 1, "-2": 1, "heavy": 1, "100": 100, "65535": 100} {
+		member := Member{Address: "10.0.0.1:5000"}
+		if value != "" {
+			member.Labels = map[string]string{LabelWeight: value}
+		}
+
+		if weight := member.Weight(); weight != expected {
+			t.Fatalf("Unexpected weight of label %q: %d, expected: %d", value, weight, expected)
+		}
+	}
+
+	member := Member{Address: "10.0.0.1:5000", Labels: map[string]string{"zone": "a"}}
+	if weighted := member.WithWeight(2); weighted.Weight() != 2 || weighted.Labels["zone"] != "a" || member.Weight() != 1 {
+		t.Fatalf("Unexpected members: %+v, %+v", member, weighted)
+	}
+}
+
+func TestSetWeight(t *testing.T) {
+	rp, _ := newTestRingpop(t)
+
+	if err := SetWeight(rp, 0); err == nil {
+		t.Fatal("Error is expected for weight 0")
+	}
+	if err := SetWeight(rp, MaxWeight+1); err == nil {
+		t.Fatalf("Error is expected for weight %d", MaxWeight+1)
+	}
+
+	if err := SetWeight(rp, 2); err != nil {
+		t.Fatalf("Unable to set weight: %v", err)
+	}
+	labels, _ := rp.Labels()
+	if weight, _ := labels.Get(LabelWeight); weight != "2" {
+		t.Fatalf("Unexpected weight label: %q", weight)
+	}
+}
+
+func TestSelectorSeesLocalMemberWeight(t *testing.T) {
+	rp, members := newTestRingpop(t)
+	s, err := NewSelector(AlgorithmRing, members)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := SetWeight(rp, 3); err != nil {
+		t.Fatalf("Unable to set weight: %v", err)
+	}
+
+	// Local member is created before membership listener is added, its changes come asynchronously
+	for i := 0; ; i++ {
+		weighted := s.Members()
+		if len(weighted) == 1 && weighted[0].Weight == 3 {
+			return
+		}
+		if i == 100 {
+			t.Fatalf("Unexpected members: %+v, expected local member with weight 3", weighted)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}